@@ -0,0 +1,264 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewTypedTarget returns a new target called name whose flags are derived
+// from the fields of struct T using tags:
+//
+//	type buildFlags struct {
+//		Tag     string        `flag:"tag" help:"Docker tag" required:"true" env:"TAG"`
+//		NoCache bool          `flag:"no-cache" help:"Do not use cache"`
+//		Timeout time.Duration `flag:"timeout" default:"5m"`
+//	}
+//
+// Tag `flag` names the flag; fields without it are ignored. Tag `help` is
+// the usage shown in help, `default` the default value, and `env` an
+// environment variable setting the flag in addition to the one named by
+// Target.FlagEnv. Flags with tag `required` set to true must get a value,
+// which can be the zero value such as `-count=0`, from the command line,
+// environment, configuration file or Target.Flags.
+//
+// Supported field types are string, bool, int, int64, uint, uint64, float64,
+// time.Duration, []string (given as comma separated list), and types whose
+// pointer implements flag.Value. Values set in Target.Flags use the name of
+// the flag as key. Once the flags are handled, do is called with the
+// populated struct.
+//
+// NewTypedTarget panics when T is not a struct or has fields with unsupported
+// types or invalid defaults.
+func NewTypedTarget[T any](name string, do func(target *Target, flags *T) error, opts ...TargetOption) *Target {
+	fields, err := flagFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		panic(fmt.Sprintf("gomake: target %s: %s", name, err))
+	}
+	if err := bindFlags(flag.NewFlagSet(name, flag.ContinueOnError), new(T), fields); err != nil {
+		panic(fmt.Sprintf("gomake: target %s: %s", name, err))
+	}
+
+	target := &Target{
+		Name: name,
+		DefineFlags: func(flagSet *flag.FlagSet) {
+			_ = bindFlags(flagSet, new(T), fields)
+		},
+		HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+			_, flagSet, err := parseTypedFlags[T](target, fields)
+			return flagSet, err
+		},
+		Do: func(target *Target) error {
+			flags, _, err := parseTypedFlags[T](target, fields)
+			if err != nil {
+				return err
+			}
+			return do(target, flags)
+		},
+	}
+
+	for _, f := range fields {
+		if f.env != "" {
+			if target.FlagEnvVars == nil {
+				target.FlagEnvVars = map[string]string{}
+			}
+			target.FlagEnvVars[f.name] = f.env
+		}
+	}
+
+	for _, opt := range opts {
+		opt(target)
+	}
+
+	return target
+}
+
+// flagField describes a struct field bound to a flag.
+type flagField struct {
+	index    int
+	name     string
+	help     string
+	def      string
+	env      string
+	required bool
+}
+
+var flagValueType = reflect.TypeOf((*flag.Value)(nil)).Elem()
+
+func flagFields(typ reflect.Type) ([]flagField, error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("flags must be defined using a struct, not %s", typ)
+	}
+
+	var fields []flagField
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		name, ok := sf.Tag.Lookup("flag")
+		if !ok || name == "-" {
+			continue
+		}
+		if !sf.IsExported() {
+			return nil, fmt.Errorf("field %s must be exported to bind flag -%s", sf.Name, name)
+		}
+
+		f := flagField{
+			index: i,
+			name:  name,
+			help:  sf.Tag.Get("help"),
+			def:   sf.Tag.Get("default"),
+			env:   sf.Tag.Get("env"),
+		}
+		if v, ok := sf.Tag.Lookup("required"); ok {
+			required, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: invalid required tag %q", sf.Name, v)
+			}
+			f.required = required
+		}
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+// bindFlags defines the flags described by fields in flagSet, storing values
+// in the fields of v, which must point to a struct.
+func bindFlags(flagSet *flag.FlagSet, v any, fields []flagField) error {
+	rv := reflect.ValueOf(v).Elem()
+
+	for _, f := range fields {
+		field := rv.Field(f.index)
+		ptr := field.Addr().Interface()
+
+		switch p := ptr.(type) {
+		case *string:
+			flagSet.StringVar(p, f.name, "", f.help)
+		case *bool:
+			flagSet.BoolVar(p, f.name, false, f.help)
+		case *int:
+			flagSet.IntVar(p, f.name, 0, f.help)
+		case *int64:
+			flagSet.Int64Var(p, f.name, 0, f.help)
+		case *uint:
+			flagSet.UintVar(p, f.name, 0, f.help)
+		case *uint64:
+			flagSet.Uint64Var(p, f.name, 0, f.help)
+		case *float64:
+			flagSet.Float64Var(p, f.name, 0, f.help)
+		case *time.Duration:
+			flagSet.DurationVar(p, f.name, 0, f.help)
+		case *[]string:
+			flagSet.Var((*commaList)(p), f.name, f.help)
+		default:
+			if !field.Addr().Type().Implements(flagValueType) {
+				return fmt.Errorf("field %s has unsupported type %s",
+					rv.Type().Field(f.index).Name, field.Type())
+			}
+			flagSet.Var(ptr.(flag.Value), f.name, f.help)
+		}
+
+		if f.def != "" {
+			fl := flagSet.Lookup(f.name)
+			if err := fl.Value.Set(f.def); err != nil {
+				return fmt.Errorf("flag -%s: invalid default %q: %w", f.name, f.def, err)
+			}
+			fl.DefValue = fl.Value.String()
+		}
+	}
+
+	return nil
+}
+
+// parseTypedFlags returns the flags of target as T. Values found in
+// Target.Flags are used unless given as argument. The resulting values are
+// stored in Target.Flags.
+func parseTypedFlags[T any](target *Target, fields []flagField) (*T, *flag.FlagSet, error) {
+	flags := new(T)
+	flagSet := flag.NewFlagSet(target.Name, flag.ContinueOnError)
+	if target.Maker != nil {
+		flagSet.SetOutput(target.Maker.StdErr)
+	}
+	if err := bindFlags(flagSet, flags, fields); err != nil {
+		return nil, nil, err
+	}
+
+	for _, f := range fields {
+		v, ok := target.Flags[f.name]
+		if !ok {
+			continue
+		}
+		if err := flagSet.Set(f.name, flagString(v)); err != nil {
+			return nil, nil, fmt.Errorf("%s: flag -%s: invalid value %v: %w", target.Name, f.name, v, err)
+		}
+	}
+
+	if err := flagSet.Parse(target.FlagArgs); err != nil {
+		return nil, nil, err
+	}
+
+	if target.Flags == nil {
+		target.Flags = map[string]any{}
+	}
+
+	// flags are set from Target.Flags or arguments, even to the zero value
+	set := map[string]bool{}
+	flagSet.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	rv := reflect.ValueOf(flags).Elem()
+	for _, f := range fields {
+		field := rv.Field(f.index)
+		if f.required && !set[f.name] {
+			return nil, nil, fmt.Errorf("%s: flag -%s is required", target.Name, f.name)
+		}
+		target.Flags[f.name] = field.Interface()
+	}
+
+	return flags, flagSet, nil
+}
+
+// flagString returns v as it would be given on the command line.
+func flagString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, ",")
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	// flag values usually implement String using a pointer receiver
+	ptr := reflect.New(reflect.TypeOf(v))
+	ptr.Elem().Set(reflect.ValueOf(v))
+	if s, ok := ptr.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// commaList is a flag.Value holding a comma separated list of strings.
+type commaList []string
+
+func (l *commaList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *commaList) Set(value string) error {
+	*l = nil
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golistic/xt"
+)
+
+type testLevel int
+
+func (l *testLevel) String() string {
+	return fmt.Sprintf("level-%d", int(*l))
+}
+
+func (l *testLevel) Set(value string) error {
+	n, err := fmt.Sscanf(value, "level-%d", (*int)(l))
+	if err != nil || n != 1 {
+		return fmt.Errorf("invalid level")
+	}
+	return nil
+}
+
+type testBuildFlags struct {
+	Tag       string        `flag:"tag" help:"Docker tag" required:"true" env:"TEST_BUILD_TAG"`
+	NoCache   bool          `flag:"no-cache" help:"Do not use cache"`
+	Retries   int           `flag:"retries" default:"3"`
+	Timeout   time.Duration `flag:"timeout" default:"5m"`
+	Platforms []string      `flag:"platforms" default:"linux/amd64,linux/arm64"`
+	Level     testLevel     `flag:"level" default:"level-1"`
+	Ignored   string
+}
+
+func TestNewTypedTarget(t *testing.T) {
+	run := func(t *testing.T, args []string, opts ...TargetOption) (*testBuildFlags, string, int) {
+		t.Helper()
+
+		var have *testBuildFlags
+		target := NewTypedTarget("typed", func(target *Target, flags *testBuildFlags) error {
+			have = flags
+			return nil
+		}, opts...)
+
+		var bufErr strings.Builder
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.StdErr = &bufErr
+		m.registerTargets(target)
+
+		code := m.make(append([]string{"typed"}, args...)...)
+		return have, bufErr.String(), code
+	}
+
+	t.Run("command line and defaults", func(t *testing.T) {
+		have, _, code := run(t, []string{"-tag", "1.2.3", "-no-cache", "-platforms", "linux/amd64"})
+		xt.Eq(t, 0, code)
+		xt.Eq(t, testBuildFlags{
+			Tag:       "1.2.3",
+			NoCache:   true,
+			Retries:   3,
+			Timeout:   5 * time.Minute,
+			Platforms: []string{"linux/amd64"},
+			Level:     1,
+		}, *have)
+	})
+
+	t.Run("values from code, environment and configuration", func(t *testing.T) {
+		configFile := filepath.Join(t.TempDir(), "gomake.toml")
+		xt.OK(t, os.WriteFile(configFile, []byte("[targets.typed.flags]\nretries = 5\nlevel = \"level-3\"\n"), 0o600))
+		t.Setenv("TEST_BUILD_TAG", "from-env")
+		t.Setenv("GOMAKE_TYPED_TIMEOUT", "1m")
+
+		var have *testBuildFlags
+		target := NewTypedTarget("typed", func(target *Target, flags *testBuildFlags) error {
+			have = flags
+			return nil
+		}, WithFlag("no-cache", true), WithFlag("retries", 4))
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.ConfigFile = configFile
+		m.registerTargets(target)
+
+		xt.Eq(t, 0, m.make("typed"))
+		xt.Eq(t, "from-env", have.Tag)
+		xt.Eq(t, true, have.NoCache)
+		xt.Eq(t, 5, have.Retries)
+		xt.Eq(t, time.Minute, have.Timeout)
+		xt.Eq(t, testLevel(3), have.Level)
+	})
+
+	t.Run("required", func(t *testing.T) {
+		_, stdErr, code := run(t, nil)
+		xt.Eq(t, 1, code)
+		xt.Eq(t, "Error: typed: flag -tag is required\n", stdErr)
+
+		have, _, code := run(t, []string{"-tag="})
+		xt.Eq(t, 0, code)
+		xt.Eq(t, "", have.Tag)
+
+		have, _, code = run(t, nil, WithFlag("tag", ""))
+		xt.Eq(t, 0, code)
+		xt.Eq(t, "", have.Tag)
+	})
+
+	t.Run("help", func(t *testing.T) {
+		target := NewTypedTarget("typed", func(*Target, *testBuildFlags) error { return nil })
+		have := helpTarget(target)
+		xt.Assert(t, strings.Contains(have, `   -tag string
+      Docker tag
+      environment: TEST_BUILD_TAG, GOMAKE_TYPED_TAG
+`), have)
+		xt.Assert(t, !strings.Contains(have, "Ignored"))
+		xt.Assert(t, strings.Contains(have, `   -timeout duration
+      (default: "5m0s")
+      environment: GOMAKE_TYPED_TIMEOUT
+`), have)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		cases := map[string]func(){
+			"gomake: target bad: flags must be defined using a struct, not string": func() {
+				NewTypedTarget("bad", func(*Target, *string) error { return nil })
+			},
+			"gomake: target bad: field C has unsupported type complex128": func() {
+				NewTypedTarget("bad", func(*Target, *struct {
+					C complex128 `flag:"c"`
+				}) error {
+					return nil
+				})
+			},
+			`gomake: target bad: flag -n: invalid default "x": parse error`: func() {
+				NewTypedTarget("bad", func(*Target, *struct {
+					N int `flag:"n" default:"x"`
+				}) error {
+					return nil
+				})
+			},
+		}
+
+		for exp, fn := range cases {
+			func() {
+				defer func() {
+					xt.Eq(t, exp, recover())
+				}()
+				fn()
+			}()
+		}
+	})
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"reflect"
+)
+
+// Clone returns a deep copy of the target. Flags, settings, messages and the
+// environment are copied, and so are the pre-targets and deferred targets,
+// so that the copy can be customized without changing the original. A
+// target used more than once within the pre-targets and deferred targets is
+// copied only once. The copy does not belong to a Maker.
+func (t *Target) Clone() *Target {
+	return t.clone(map[*Target]*Target{})
+}
+
+func (t *Target) clone(seen map[*Target]*Target) *Target {
+	if c, ok := seen[t]; ok {
+		return c
+	}
+
+	c := &Target{}
+	seen[t] = c
+
+	*c = *t
+	c.Maker = nil
+	c.report = nil
+	c.instances = nil
+	c.outputs = nil
+
+	c.FlagArgs = cloneValue(t.FlagArgs)
+	c.Flags = cloneValue(t.Flags)
+	c.Settings = cloneValue(t.Settings)
+	c.PreMessages = cloneValue(t.PreMessages)
+	c.PostMessages = cloneValue(t.PostMessages)
+	c.FlagEnvVars = cloneValue(t.FlagEnvVars)
+	c.Params = cloneValue(t.Params)
+	c.Env = Env{
+		Set:      cloneValue(t.Env.Set),
+		Unset:    cloneValue(t.Env.Unset),
+		Hermetic: t.Env.Hermetic,
+		Allow:    cloneValue(t.Env.Allow),
+	}
+
+	c.PreTargets = cloneTargets(t.PreTargets, seen)
+	c.DeferredTargets = cloneTargets(t.DeferredTargets, seen)
+	c.children = cloneTargets(t.children, seen)
+
+	return c
+}
+
+func cloneTargets(targets []*Target, seen map[*Target]*Target) []*Target {
+	if targets == nil {
+		return nil
+	}
+
+	res := make([]*Target, len(targets))
+	for i, t := range targets {
+		res[i] = t.clone(seen)
+	}
+	return res
+}
+
+// cloneValue returns a deep copy of maps and slices found in v. Other values,
+// including pointers and functions, are shared.
+func cloneValue[T any](v T) T {
+	c, _ := deepCopy(reflect.ValueOf(&v).Elem()).Interface().(T)
+	return c
+}
+
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestTarget_Clone(t *testing.T) {
+	shared := &Target{Name: "shared", Flags: map[string]any{"out": "vendor"}}
+	orig := &Target{
+		Name:            "orig",
+		Maker:           NewMaker(),
+		FlagArgs:        []string{"-tag", "1.0"},
+		Flags:           map[string]any{"tags": []string{"a", "b"}},
+		Settings:        map[string]any{"integration": [][]string{{"go", "version"}}},
+		PreMessages:     []string{"pre"},
+		Env:             Env{Set: map[string]string{"CGO_ENABLED": "0"}, Allow: []string{"HOME"}},
+		PreTargets:      []*Target{shared},
+		DeferredTargets: []*Target{shared},
+	}
+
+	clone := orig.Clone()
+	xt.Eq(t, orig.Flags, clone.Flags)
+	xt.Eq(t, orig.Settings, clone.Settings)
+	xt.Assert(t, clone.Maker == nil, "clone must not belong to a Maker")
+
+	clone.FlagArgs[1] = "2.0"
+	clone.Flags["tags"].([]string)[0] = "changed"
+	clone.Settings["integration"].([][]string)[0][1] = "env"
+	clone.PreMessages[0] = "changed"
+	clone.Env.Set["CGO_ENABLED"] = "1"
+	clone.Env.Allow[0] = "PATH"
+	clone.PreTargets[0].Flags["out"] = "_vendor"
+
+	xt.Eq(t, []string{"-tag", "1.0"}, orig.FlagArgs)
+	xt.Eq(t, []string{"a", "b"}, orig.Flags["tags"])
+	xt.Eq(t, [][]string{{"go", "version"}}, orig.Settings["integration"])
+	xt.Eq(t, []string{"pre"}, orig.PreMessages)
+	xt.Eq(t, "0", orig.Env.Set["CGO_ENABLED"])
+	xt.Eq(t, []string{"HOME"}, orig.Env.Allow)
+	xt.Eq(t, "vendor", shared.Flags["out"])
+
+	// a target used more than once is cloned once
+	xt.Assert(t, clone.PreTargets[0] == clone.DeferredTargets[0], "expected same clone")
+	xt.Assert(t, clone.PreTargets[0] != shared, "expected pre-target to be cloned")
+}
+
+func TestNewTargets(t *testing.T) {
+	vendor := NewVendorTarget(WithName("vendor-for-docker"), WithFlag("out", "_vendor"))
+	cleanup := NewCleanupVendorTarget(WithFlag("out", "_vendor"))
+
+	target := NewDockerBuildXPushTarget(
+		WithFlags(map[string]any{"registry": "ghcr.io/org", "image": "myapp"}),
+		WithPreTargets(vendor),
+		WithDeferredTargets(cleanup),
+		WithEnvVar("DOCKER_BUILDKIT", "1"),
+		WithWorkDir("build"),
+	)
+
+	xt.Eq(t, "docker-buildx", target.Name)
+	xt.Eq(t, map[string]any{"registry": "ghcr.io/org", "image": "myapp"}, target.Flags)
+	xt.Eq(t, "vendor-for-docker", target.PreTargets[0].Name)
+	xt.Eq(t, "_vendor", target.DeferredTargets[0].Flags["out"])
+	xt.Eq(t, "1", target.Env.Set["DOCKER_BUILDKIT"])
+	xt.Eq(t, "build", target.WorkDir)
+
+	// stock targets are left untouched
+	xt.Eq(t, "vendor", TargetVendor.Name)
+	xt.Assert(t, TargetVendor.Flags == nil)
+	xt.Assert(t, TargetDockerBuildXPush.Flags == nil)
+
+	called := false
+	custom := NewTarget("custom", func(*Target) error {
+		called = true
+		return nil
+	}, WithDescription("Custom target"), WithSetting("key", "value"))
+	xt.Eq(t, "Custom target", custom.Description)
+	xt.Eq(t, "value", custom.Settings["key"])
+	xt.OK(t, custom.Do(custom))
+	xt.Assert(t, called)
+}
@@ -0,0 +1,407 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const gomakeImport = "github.com/golistic/gomake"
+
+// genDefaultOut is the name of the file written by the gen command.
+const genDefaultOut = "gomake_gen.go"
+
+// genIgnoreDirective excludes an exported function from the generated
+// targets when found in its doc comment.
+const genIgnoreDirective = "gomake:ignore"
+
+// genFlagTypes maps the types of supported function parameters to the type
+// used in the generated flags struct.
+var genFlagTypes = map[string]string{
+	"string":        "string",
+	"bool":          "bool",
+	"int":           "int",
+	"int64":         "int64",
+	"uint":          "uint",
+	"uint64":        "uint64",
+	"float64":       "float64",
+	"time.Duration": "time.Duration",
+	"[]string":      "[]string",
+}
+
+func runGen(args []string, _, stdErr io.Writer) error {
+	flagSet := flag.NewFlagSet("gen", flag.ContinueOnError)
+	flagSet.SetOutput(stdErr)
+	flagSet.Usage = func() {
+		_, _ = fmt.Fprint(stdErr, `Usage: gomake gen [-dir DIR] [-out FILE]
+
+Generates a target for each exported function of the Go package in DIR, and
+registers them with gomake. Supported functions take, optionally, a
+*gomake.Target as first parameter, followed by parameters which become flags,
+and return nothing or an error. Functions with "gomake:ignore" in their doc
+comment are skipped. Use in the package as:
+
+	//go:generate go run github.com/golistic/gomake/cmd/gomake gen
+
+Flags:
+`)
+		flagSet.PrintDefaults()
+	}
+	dir := flagSet.String("dir", ".", "Directory of the package to scan")
+	out := flagSet.String("out", genDefaultOut, "File to write, relative to the package directory")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	outPath := *out
+	if !filepath.IsAbs(outPath) {
+		outPath = filepath.Join(*dir, outPath)
+	}
+
+	src, err := generate(*dir, outPath, stdErr)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(outPath, src, 0o644)
+}
+
+// genTarget describes a target generated for a function.
+type genTarget struct {
+	Name        string
+	Description string
+	Func        string
+	WithTarget  bool
+	ReturnsErr  bool
+	FlagsType   string
+	Flags       []genFlag
+}
+
+type genFlag struct {
+	Field string
+	Type  string
+	Name  string
+}
+
+// Args returns the arguments with which the function is called.
+func (t genTarget) Args() string {
+	var args []string
+	if t.WithTarget {
+		args = append(args, "target")
+	}
+	for _, f := range t.Flags {
+		args = append(args, "flags."+f.Field)
+	}
+	return strings.Join(args, ", ")
+}
+
+// generate scans the package in dir and returns the source of the file
+// registering its targets. Functions which are skipped are reported to
+// stdErr. The file at outPath is not scanned.
+func generate(dir, outPath string, stdErr io.Writer) ([]byte, error) {
+	outAbs, err := filepath.Abs(outPath)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		if strings.HasSuffix(fi.Name(), "_test.go") {
+			return false
+		}
+		p, err := filepath.Abs(filepath.Join(dir, fi.Name()))
+		return err != nil || p != outAbs
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no Go package found in %s", dir)
+	}
+	if len(pkgs) > 1 {
+		return nil, fmt.Errorf("more than one Go package found in %s", dir)
+	}
+
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+
+	var targets []genTarget
+	fileNames := make([]string, 0, len(pkg.Files))
+	for name := range pkg.Files {
+		fileNames = append(fileNames, name)
+	}
+	sort.Strings(fileNames)
+
+	for _, name := range fileNames {
+		file := pkg.Files[name]
+		if isGenerated(file) {
+			continue
+		}
+		imports := fileImports(file)
+
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !fn.Name.IsExported() || fn.Type.TypeParams != nil {
+				continue
+			}
+			if fn.Doc != nil && strings.Contains(fn.Doc.Text(), genIgnoreDirective) {
+				continue
+			}
+
+			target, err := genTargetOf(fn, imports)
+			if err != nil {
+				_, _ = fmt.Fprintf(stdErr, "%s: skipping %s: %s\n", fset.Position(fn.Pos()), fn.Name.Name, err)
+				continue
+			}
+			targets = append(targets, target)
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no exported functions with supported signature found in %s", dir)
+	}
+
+	return renderGen(pkg.Name, targets)
+}
+
+func isGenerated(file *ast.File) bool {
+	for _, cg := range file.Comments {
+		if cg.Pos() > file.Package {
+			return false
+		}
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "// Code generated ") && strings.HasSuffix(c.Text, " DO NOT EDIT.") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fileImports maps the names by which packages are known in file to their
+// import paths.
+func fileImports(file *ast.File) map[string]string {
+	res := map[string]string{}
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := path[strings.LastIndex(path, "/")+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		res[name] = path
+	}
+	return res
+}
+
+func genTargetOf(fn *ast.FuncDecl, imports map[string]string) (genTarget, error) {
+	target := genTarget{
+		Name:        kebabCase(fn.Name.Name),
+		Description: synopsis(fn.Doc),
+		Func:        fn.Name.Name,
+		FlagsType:   "gomake" + fn.Name.Name + "Flags",
+	}
+
+	if results := fn.Type.Results; results != nil && len(results.List) > 0 {
+		ident, ok := results.List[0].Type.(*ast.Ident)
+		if len(results.List) > 1 || len(results.List[0].Names) > 1 || !ok || ident.Name != "error" {
+			return target, errors.New("must return nothing or an error")
+		}
+		target.ReturnsErr = true
+	}
+
+	seen := map[string]bool{}
+	for i, param := range fn.Type.Params.List {
+		typeName := typeString(param.Type, imports)
+
+		if i == 0 && typeName == "*"+gomakeImport+".Target" {
+			if len(param.Names) > 1 {
+				return target, errors.New("only one *gomake.Target parameter is supported")
+			}
+			target.WithTarget = true
+			continue
+		}
+
+		flagType, ok := genFlagTypes[typeName]
+		if !ok {
+			return target, fmt.Errorf("parameter type %s not supported", typeName)
+		}
+		if len(param.Names) == 0 {
+			return target, errors.New("parameters must be named")
+		}
+
+		for _, n := range param.Names {
+			name := kebabCase(n.Name)
+			if n.Name == "_" || seen[name] {
+				return target, fmt.Errorf("parameter %s cannot be used as flag", n.Name)
+			}
+			seen[name] = true
+
+			target.Flags = append(target.Flags, genFlag{
+				Field: exportedName(n.Name),
+				Type:  flagType,
+				Name:  name,
+			})
+		}
+	}
+
+	return target, nil
+}
+
+// typeString returns the type expressed by expr using import paths for
+// qualified identifiers, except for the time package.
+func typeString(expr ast.Expr, imports map[string]string) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.StarExpr:
+		return "*" + typeString(e.X, imports)
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return "[]" + typeString(e.Elt, imports)
+		}
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok {
+			path := imports[x.Name]
+			if path == "time" {
+				return "time." + e.Sel.Name
+			}
+			return path + "." + e.Sel.Name
+		}
+	case *ast.Ellipsis:
+		return "..." + typeString(e.Elt, imports)
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+// synopsis returns the first sentence of the doc comment.
+func synopsis(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+
+	var lines []string
+	for _, line := range strings.Split(doc.Text(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if len(lines) > 0 {
+				break
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	text := strings.Join(lines, " ")
+	if i := strings.Index(text, ". "); i >= 0 {
+		text = text[:i+1]
+	}
+	return text
+}
+
+// kebabCase returns s, written in camel case, using lower case words separated
+// by dashes; for example, BuildHTTPServer becomes build-http-server.
+func kebabCase(s string) string {
+	runes := []rune(s)
+	var sb strings.Builder
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				sb.WriteByte('-')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+
+	return sb.String()
+}
+
+func exportedName(s string) string {
+	runes := []rune(s)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+var genTemplate = template.Must(template.New("gen").Parse(`// Code generated by gomake gen; DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+{{- if .UsesTime }}
+	"time"
+{{ end }}
+	"github.com/golistic/gomake"
+)
+{{ range .Targets }}{{ if .Flags }}
+type {{ .FlagsType }} struct {
+{{- range .Flags }}
+	{{ .Field }} {{ .Type }} ` + "`" + `flag:"{{ .Name }}"` + "`" + `
+{{- end }}
+}
+{{ end }}{{ end }}
+func init() {
+	gomake.RegisterTargets(
+{{- range .Targets }}
+{{- if .Flags }}
+		gomake.NewTypedTarget({{ printf "%q" .Name }}, func(target *gomake.Target, flags *{{ .FlagsType }}) error {
+{{- else }}
+		gomake.NewTarget({{ printf "%q" .Name }}, func(target *gomake.Target) error {
+{{- end }}
+{{- if .ReturnsErr }}
+			return {{ .Func }}({{ .Args }})
+{{- else }}
+			{{ .Func }}({{ .Args }})
+			return nil
+{{- end }}
+		}{{ if .Description }}, gomake.WithDescription({{ printf "%q" .Description }}){{ end }}),
+{{- end }}
+	)
+}
+`))
+
+func renderGen(pkgName string, targets []genTarget) ([]byte, error) {
+	usesTime := false
+	for _, t := range targets {
+		for _, f := range t.Flags {
+			if f.Type == "time.Duration" {
+				usesTime = true
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	err := genTemplate.Execute(&buf, map[string]any{
+		"Package":  pkgName,
+		"Targets":  targets,
+		"UsesTime": usesTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestKebabCase(t *testing.T) {
+	cases := map[string]string{
+		"Build":           "build",
+		"DockerBuild":     "docker-build",
+		"BuildHTTPServer": "build-http-server",
+		"GoVersion2":      "go-version2",
+		"noCache":         "no-cache",
+		"ID":              "id",
+	}
+
+	for in, exp := range cases {
+		xt.Eq(t, exp, kebabCase(in))
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dir := filepath.Join("testdata", "example")
+
+	t.Run("matches committed file", func(t *testing.T) {
+		var stdErr strings.Builder
+		have, err := generate(dir, filepath.Join(dir, genDefaultOut), &stdErr)
+		xt.OK(t, err)
+
+		exp, err := os.ReadFile(filepath.Join(dir, genDefaultOut))
+		xt.OK(t, err)
+		xt.Eq(t, string(exp), string(have))
+		xt.Eq(t, filepath.Join(dir, "targets.go")+":36:1: skipping Skipped: must return nothing or an error\n",
+			stdErr.String())
+	})
+
+	t.Run("generated code runs", func(t *testing.T) {
+		cmd := exec.Command("go", "run", "./"+filepath.ToSlash(dir), "-log-dir", "", "release", "-retries", "3")
+		out, err := cmd.CombinedOutput()
+		xt.KO(t, err)
+		xt.Assert(t, strings.Contains(string(out), "Error: retries 3, parallel 0"), string(out))
+	})
+
+	t.Run("command writes file", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "targets_gen.go")
+
+		var stdOut, stdErr strings.Builder
+		xt.Eq(t, 0, run([]string{"gen", "-dir", dir, "-out", out}, &stdOut, &stdErr))
+
+		have, err := os.ReadFile(out)
+		xt.OK(t, err)
+		xt.Assert(t, strings.HasPrefix(string(have), "// Code generated by gomake gen; DO NOT EDIT."))
+	})
+
+	t.Run("no targets", func(t *testing.T) {
+		empty := t.TempDir()
+		xt.OK(t, os.WriteFile(filepath.Join(empty, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o600))
+
+		_, err := generate(empty, filepath.Join(empty, genDefaultOut), &strings.Builder{})
+		xt.KO(t, err)
+		xt.Eq(t, "no exported functions with supported signature found in "+empty, err.Error())
+	})
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// initDefaultOut is the file written by the init command, relative to the
+// root of the module.
+const initDefaultOut = "cmd/make/main.go"
+
+// initIgnore is the entry init adds to .gitignore, so that log files written
+// by runs are not committed. Plugins, also kept in .gomake, can be.
+const initIgnore = "/.gomake/logs/"
+
+// initLintConfigs are the configuration files of golangci-lint.
+var initLintConfigs = []string{".golangci.yml", ".golangci.yaml", ".golangci.toml", ".golangci.json"}
+
+var (
+	reModulePath   = regexp.MustCompile(`(?m)^module\s+"?([^\s"]+)"?`)
+	reMajorVersion = regexp.MustCompile(`^v[0-9]+$`)
+)
+
+func runInit(args []string, stdOut, stdErr io.Writer) error {
+	flagSet := flag.NewFlagSet("init", flag.ContinueOnError)
+	flagSet.SetOutput(stdErr)
+	flagSet.Usage = func() {
+		_, _ = fmt.Fprint(stdErr, `Usage: gomake init [-dir DIR] [-out FILE] [-force]
+
+Writes a main package registering the stock targets which are relevant for
+the Go module in DIR, and adds `+initIgnore+` to its .gitignore:
+
+	go-version, go-coverage  always
+	go-lint                  when golangci-lint is configured
+	badges                   when _badges/badges.json exists
+	docker-build             when a Dockerfile exists, naming the image after the module
+
+The integration setting of go-coverage runs go-version using the main package
+built with coverage; add targets exercising your own code to it.
+
+Flags:
+`)
+		flagSet.PrintDefaults()
+	}
+	dir := flagSet.String("dir", ".", "Root directory of the Go module")
+	out := flagSet.String("out", initDefaultOut, "File to write, relative to the root of the module")
+	force := flagSet.Bool("force", false, "Overwrite the file to write when it exists")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	p, err := inspectProject(*dir, *out)
+	if err != nil {
+		return err
+	}
+
+	dest := filepath.Join(*dir, *out)
+	if _, err := os.Stat(dest); err == nil && !*force {
+		return fmt.Errorf("%s exists; use -force to overwrite", dest)
+	}
+
+	src, err := p.render()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, src, 0o644); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdOut, "wrote %s with targets %s\n", dest, strings.Join(p.targetNames(), ", "))
+
+	added, err := addIgnore(filepath.Join(*dir, ".gitignore"), initIgnore)
+	if err != nil {
+		return err
+	}
+	if added {
+		_, _ = fmt.Fprintf(stdOut, "added %s to %s\n", initIgnore, filepath.Join(*dir, ".gitignore"))
+	}
+
+	if !p.requiresGomake {
+		_, _ = fmt.Fprintf(stdOut, "add gomake to the module using: go get %s\n", gomakeImport)
+	}
+	_, _ = fmt.Fprintf(stdOut, "show the targets using: go run %s\n", p.pkg)
+	return nil
+}
+
+// addIgnore appends entry to the .gitignore file at path, creating it when
+// needed. It returns false when the file already has entry, with or without
+// leading and trailing slash.
+func addIgnore(path, entry string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.Trim(strings.TrimSpace(line), "/") == strings.Trim(entry, "/") {
+			return false, nil
+		}
+	}
+
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	return true, os.WriteFile(path, append(data, entry+"\n"...), 0o644)
+}
+
+// project is what init found out about a Go module.
+type project struct {
+	module         string
+	pkg            string
+	requiresGomake bool
+	lint           bool
+	badges         bool
+	docker         bool
+}
+
+// inspectProject inspects the Go module in dir, for which the main package
+// is written to out.
+func inspectProject(dir, out string) (*project, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no go.mod found in %s; init must be run in the root of a Go module", dir)
+		}
+		return nil, err
+	}
+
+	m := reModulePath.FindSubmatch(data)
+	if m == nil {
+		return nil, errors.New("go.mod does not define the module path")
+	}
+
+	p := &project{
+		module: string(m[1]),
+		pkg:    "./" + path.Dir(filepath.ToSlash(filepath.Clean(out))),
+	}
+	p.requiresGomake = p.module == gomakeImport || bytes.Contains(data, []byte(gomakeImport+" "))
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	for _, name := range initLintConfigs {
+		p.lint = p.lint || exists(name)
+	}
+	p.badges = exists(filepath.Join("_badges", "badges.json"))
+	p.docker = exists("Dockerfile")
+
+	return p, nil
+}
+
+// image returns the name of the Docker image, which is the last element of
+// the module path without a major version suffix.
+func (p *project) image() string {
+	elems := strings.Split(p.module, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && reMajorVersion.MatchString(name) {
+		name = elems[len(elems)-2]
+	}
+	return strings.ToLower(name)
+}
+
+// targetNames returns the names of the targets registered by the generated
+// main package.
+func (p *project) targetNames() []string {
+	names := []string{"go-version"}
+	if p.lint {
+		names = append(names, "go-lint")
+	}
+	if p.badges {
+		names = append(names, "badges")
+	}
+	names = append(names, "go-coverage")
+	if p.docker {
+		names = append(names, "docker-build")
+	}
+	return names
+}
+
+var initTemplate = template.Must(template.New("init").Funcs(template.FuncMap{
+	"str": goString,
+}).Parse(`// Generated by gomake init for {{ .Module }}.
+
+package main
+
+import "github.com/golistic/gomake"
+
+func main() {
+	gomake.RegisterTargets(
+		gomake.NewGoVersionTarget(),
+{{- if .Lint }}
+		gomake.NewGoLintTarget(),
+{{- end }}
+{{- if .Badges }}
+		gomake.NewBadgesTarget(),
+{{- end }}
+		gomake.NewGoCoverageTarget(gomake.WithSetting("integration", [][]string{
+			{"go", "run", "-cover", {{ str .Pkg }}, "go-version"},
+		})),
+{{- if .Docker }}
+		gomake.NewDockerBuildTarget(gomake.WithFlag("image", {{ str .Image }})),
+{{- end }}
+	)
+	gomake.Make()
+}
+`))
+
+// render returns the source of the main package.
+func (p *project) render() ([]byte, error) {
+	var buf bytes.Buffer
+	err := initTemplate.Execute(&buf, map[string]any{
+		"Module": p.module,
+		"Pkg":    p.pkg,
+		"Lint":   p.lint,
+		"Badges": p.badges,
+		"Docker": p.docker,
+		"Image":  p.image(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestInit(t *testing.T) {
+	newProject := func(t *testing.T, goMod string, files ...string) string {
+		t.Helper()
+		dir := t.TempDir()
+		xt.OK(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o600))
+		for _, name := range files {
+			p := filepath.Join(dir, name)
+			xt.OK(t, os.MkdirAll(filepath.Dir(p), 0o700))
+			xt.OK(t, os.WriteFile(p, nil, 0o600))
+		}
+		return dir
+	}
+
+	t.Run("all stock targets", func(t *testing.T) {
+		dir := newProject(t, "module example.com/acme/Shop/v2\n\ngo 1.20\n\nrequire github.com/golistic/gomake v0.4.0\n",
+			"Dockerfile", ".golangci.yaml", filepath.Join("_badges", "badges.json"))
+
+		var stdOut, stdErr strings.Builder
+		xt.Eq(t, 0, run([]string{"init", "-dir", dir}, &stdOut, &stdErr), stdErr.String())
+
+		data, err := os.ReadFile(filepath.Join(dir, "cmd", "make", "main.go"))
+		xt.OK(t, err)
+		xt.Eq(t, `// Generated by gomake init for example.com/acme/Shop/v2.
+
+package main
+
+import "github.com/golistic/gomake"
+
+func main() {
+	gomake.RegisterTargets(
+		gomake.NewGoVersionTarget(),
+		gomake.NewGoLintTarget(),
+		gomake.NewBadgesTarget(),
+		gomake.NewGoCoverageTarget(gomake.WithSetting("integration", [][]string{
+			{"go", "run", "-cover", "./cmd/make", "go-version"},
+		})),
+		gomake.NewDockerBuildTarget(gomake.WithFlag("image", "shop")),
+	)
+	gomake.Make()
+}
+`, string(data))
+
+		out := stdOut.String()
+		xt.Assert(t, strings.Contains(out, "with targets go-version, go-lint, badges, go-coverage, docker-build\n"), out)
+		xt.Assert(t, !strings.Contains(out, "go get"), out)
+		xt.Assert(t, strings.Contains(out, "added /.gomake/logs/ to "), out)
+
+		ignore, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+		xt.OK(t, err)
+		xt.Eq(t, "/.gomake/logs/\n", string(ignore))
+	})
+
+	t.Run("minimal", func(t *testing.T) {
+		dir := newProject(t, "module example.com/tool\n")
+		xt.OK(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("/bin\n.gomake/logs"), 0o600))
+
+		var stdOut, stdErr strings.Builder
+		xt.Eq(t, 0, run([]string{"init", "-dir", dir, "-out", "tools/make/main.go"}, &stdOut, &stdErr),
+			stdErr.String())
+
+		data, err := os.ReadFile(filepath.Join(dir, "tools", "make", "main.go"))
+		xt.OK(t, err)
+		src := string(data)
+		xt.Assert(t, strings.Contains(src, `{"go", "run", "-cover", "./tools/make", "go-version"},`+"\n\t\t})),"), src)
+		xt.Assert(t, !strings.Contains(src, "Lint"), src)
+		xt.Assert(t, !strings.Contains(src, "Docker"), src)
+		xt.Assert(t, strings.Contains(stdOut.String(), "go get github.com/golistic/gomake\n"), stdOut.String())
+		xt.Assert(t, !strings.Contains(stdOut.String(), "added"), stdOut.String())
+
+		stdErr.Reset()
+		xt.Eq(t, 1, run([]string{"init", "-dir", dir, "-out", "tools/make/main.go"}, &stdOut, &stdErr))
+		xt.Eq(t, "gomake init: "+filepath.Join(dir, "tools", "make", "main.go")+" exists; use -force to overwrite\n",
+			stdErr.String())
+	})
+
+	t.Run("generated code runs", func(t *testing.T) {
+		root, err := filepath.Abs(filepath.Join("..", ".."))
+		xt.OK(t, err)
+
+		dir := newProject(t, "module example.com/tool\n\ngo 1.20\n\n"+
+			"require github.com/golistic/gomake v0.0.0\n\n"+
+			"replace github.com/golistic/gomake => "+filepath.ToSlash(root)+"\n", ".golangci.yml")
+		sums, err := os.ReadFile(filepath.Join(root, "go.sum"))
+		xt.OK(t, err)
+		xt.OK(t, os.WriteFile(filepath.Join(dir, "go.sum"), sums, 0o600))
+
+		var stdOut, stdErr strings.Builder
+		xt.Eq(t, 0, run([]string{"init", "-dir", dir}, &stdOut, &stdErr), stdErr.String())
+
+		cmd := exec.Command("go", "run", "./cmd/make", "-log-dir", "", "help")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+		out, err := cmd.CombinedOutput()
+		xt.OK(t, err, string(out))
+		for _, name := range []string{"go-version", "go-lint", "go-coverage"} {
+			xt.Assert(t, strings.Contains(string(out), "   "+name+"\n"), string(out))
+		}
+	})
+
+	t.Run("not a Go module", func(t *testing.T) {
+		dir := t.TempDir()
+
+		var stdOut, stdErr strings.Builder
+		xt.Eq(t, 1, run([]string{"init", "-dir", dir}, &stdOut, &stdErr))
+		xt.Eq(t, "gomake init: no go.mod found in "+dir+"; init must be run in the root of a Go module\n",
+			stdErr.String())
+	})
+}
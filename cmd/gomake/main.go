@@ -0,0 +1,66 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+// Command gomake provides tools supporting projects using the gomake
+// package.
+//
+// Usage:
+//
+//	gomake <command> [flags]
+//
+// The commands are:
+//
+//	gen              generate targets for exported functions of a package
+//	import-makefile  translate a Makefile into targets
+//	init             generate a main package registering stock targets
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string, stdOut, stdErr io.Writer) error
+}
+
+var commands = []command{
+	{name: "gen", description: "Generate targets for exported functions of a package", run: runGen},
+	{name: "init", description: "Generate a main package registering stock targets", run: runInit},
+	{name: "import-makefile", description: "Translate a Makefile into targets", run: runImportMakefile},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdOut, stdErr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdOut)
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		if err := cmd.run(args[1:], stdOut, stdErr); err != nil {
+			_, _ = fmt.Fprintf(stdErr, "gomake %s: %s\n", cmd.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	_, _ = fmt.Fprintf(stdErr, "gomake: unknown command %s\n\n", args[0])
+	usage(stdErr)
+	return 1
+}
+
+func usage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "Usage: gomake <command> [flags]\n\nCommands:")
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(w, "   %-16s %s\n", cmd.name, cmd.description)
+	}
+}
@@ -0,0 +1,768 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// importDefaultOut is the file written by the import-makefile command.
+const importDefaultOut = "cmd/make/main.go"
+
+func runImportMakefile(args []string, _, stdErr io.Writer) error {
+	flagSet := flag.NewFlagSet("import-makefile", flag.ContinueOnError)
+	flagSet.SetOutput(stdErr)
+	flagSet.Usage = func() {
+		_, _ = fmt.Fprint(stdErr, `Usage: gomake import-makefile [-f FILE] [-out FILE] [-force]
+
+Translates the rules of a Makefile into targets, writing a main package
+which runs them using gomake. Supported are rules with prerequisites and
+recipes, .PHONY, variables assigned using =, :=, ::=, ?=, += and !=,
+automatic variables $@, $<, $^ and $+, and $(shell ...). Variables assigned
+using ?= can be overridden using the environment. Constructs which cannot be
+translated, such as conditionals and pattern rules, are reported and listed
+at the top of the generated file.
+
+Flags:
+`)
+		flagSet.PrintDefaults()
+	}
+	in := flagSet.String("f", "Makefile", "Makefile to translate")
+	out := flagSet.String("out", importDefaultOut, "File to write")
+	force := flagSet.Bool("force", false, "Overwrite the file to write when it exists")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(*out); err == nil && !*force {
+		return fmt.Errorf("%s exists; use -force to overwrite", *out)
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+
+	mf := parseMakefile(*in, data)
+	src, err := mf.render()
+	if err != nil {
+		return err
+	}
+
+	for _, w := range mf.sortedWarnings() {
+		_, _ = fmt.Fprintln(stdErr, w)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
+
+// makefile holds what was understood of a Makefile.
+type makefile struct {
+	path     string
+	vars     map[string]*makeVar
+	rules    []*makeRule
+	byName   map[string]*makeRule
+	phony    map[string]bool
+	warnings []makeWarning
+}
+
+// makeWarning reports something which was not translated, or translated
+// differently.
+type makeWarning struct {
+	line int
+	msg  string
+}
+
+// makeVar is a variable of a Makefile. Values of recursive variables are
+// expanded when used; values of simple variables were expanded when they
+// were assigned.
+type makeVar struct {
+	value  string
+	simple bool
+	// weak variables were assigned using ?= and can be overridden using
+	// the environment
+	weak bool
+}
+
+type makeRule struct {
+	name        string
+	prereqs     []string
+	recipe      []string
+	description string
+	line        int
+}
+
+var (
+	reAssignment = regexp.MustCompile(`^(?:export\s+)?([^\s:#=]+)\s*(\?=|\+=|::=|:=|!=|=)\s*(.*)$`)
+	reDescSuffix = regexp.MustCompile(`\s##\s*(.*)$`)
+)
+
+// specialTargets are special built-in target names of GNU Make which are
+// not translated.
+var specialTargets = map[string]bool{
+	".SUFFIXES": true, ".DEFAULT": true, ".PRECIOUS": true, ".INTERMEDIATE": true,
+	".SECONDARY": true, ".SECONDEXPANSION": true, ".DELETE_ON_ERROR": true,
+	".IGNORE": true, ".LOW_RESOLUTION_TIME": true, ".SILENT": true,
+	".EXPORT_ALL_VARIABLES": true, ".NOTPARALLEL": true, ".ONESHELL": true,
+	".POSIX": true, ".NOTINTERMEDIATE": true,
+}
+
+// parseMakefile parses the subset of GNU Make syntax which can be
+// translated. Everything else is reported as warning.
+func parseMakefile(path string, data []byte) *makefile {
+	mf := &makefile{
+		path:   path,
+		vars:   map[string]*makeVar{},
+		byName: map[string]*makeRule{},
+		phony:  map[string]bool{},
+	}
+
+	var current []*makeRule // rules receiving recipe lines
+	var comment []string    // comment lines preceding a rule
+	var conds []bool        // for each conditional, whether its lines are skipped
+	var inDefine bool
+
+	lines := logicalLines(data)
+	for _, ll := range lines {
+		line, num := ll.text, ll.num
+
+		if inDefine {
+			if strings.TrimSpace(line) == "endef" {
+				inDefine = false
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "\t") && current != nil {
+			if !skipping(conds) {
+				recipe := strings.TrimSpace(line)
+				if recipe != "" {
+					for _, r := range current {
+						r.recipe = append(r.recipe, recipe)
+					}
+				}
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			comment = nil
+			continue
+		}
+		if strings.HasPrefix(trimmed, "#") {
+			comment = append(comment, strings.TrimSpace(strings.TrimLeft(trimmed, "#")))
+			continue
+		}
+
+		word, rest, _ := strings.Cut(trimmed, " ")
+		switch word {
+		case "ifeq", "ifneq", "ifdef", "ifndef":
+			mf.warnf(num, "conditional %s is not evaluated; using its first branch", word)
+			conds = append(conds, false)
+			continue
+		case "else":
+			if len(conds) == 0 {
+				mf.warnf(num, "else without conditional")
+			} else {
+				conds[len(conds)-1] = true
+			}
+			continue
+		case "endif":
+			if len(conds) == 0 {
+				mf.warnf(num, "endif without conditional")
+			} else {
+				conds = conds[:len(conds)-1]
+			}
+			continue
+		}
+
+		if skipping(conds) {
+			continue
+		}
+
+		desc := strings.Join(comment, " ")
+		comment = nil
+		current = nil
+
+		switch word {
+		case "define":
+			mf.warnf(num, "multi-line variable %s is not supported; skipped", strings.TrimSpace(rest))
+			inDefine = true
+			continue
+		case "include", "-include", "sinclude":
+			mf.warnf(num, "%s is not supported; skipped", trimmed)
+			continue
+		case "export", "unexport", "override", "vpath", "undefine":
+			if word != "export" || !reAssignment.MatchString(trimmed) {
+				mf.warnf(num, "%s is not supported; skipped", word)
+				continue
+			}
+			mf.warnf(num, "export is not supported; %s is only substituted", reAssignment.FindStringSubmatch(trimmed)[1])
+		}
+
+		if m := reAssignment.FindStringSubmatch(stripComment(trimmed)); m != nil {
+			mf.assign(m[1], m[2], m[3], num)
+			continue
+		}
+
+		if strings.Contains(trimmed, ":") {
+			current = mf.parseRule(trimmed, desc, num)
+			continue
+		}
+
+		mf.warnf(num, "line not understood; skipped")
+	}
+
+	if len(conds) > 0 {
+		mf.warnf(len(lines), "missing endif")
+	}
+
+	return mf
+}
+
+type logicalLine struct {
+	text string
+	num  int
+}
+
+// logicalLines returns the lines of data, joining lines ending with a
+// backslash with the next one.
+func logicalLines(data []byte) []logicalLine {
+	var res []logicalLine
+	var sb strings.Builder
+	start := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for num := 1; scanner.Scan(); num++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if sb.Len() == 0 {
+			start = num
+		} else {
+			line = strings.TrimLeft(line, " \t")
+		}
+
+		if strings.HasSuffix(line, `\`) && !strings.HasSuffix(line, `\\`) {
+			sb.WriteString(strings.TrimRight(line[:len(line)-1], " \t") + " ")
+			continue
+		}
+
+		sb.WriteString(line)
+		res = append(res, logicalLine{text: sb.String(), num: start})
+		sb.Reset()
+	}
+	if sb.Len() > 0 {
+		res = append(res, logicalLine{text: sb.String(), num: start})
+	}
+
+	return res
+}
+
+func skipping(conds []bool) bool {
+	for _, skip := range conds {
+		if skip {
+			return true
+		}
+	}
+	return false
+}
+
+// stripComment removes a comment, unless its # is escaped.
+func stripComment(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && (i == 0 || s[i-1] != '\\') {
+			return strings.TrimSpace(s[:i])
+		}
+	}
+	return s
+}
+
+func (mf *makefile) warnf(line int, format string, a ...any) {
+	mf.warnings = append(mf.warnings, makeWarning{line: line, msg: fmt.Sprintf(format, a...)})
+}
+
+// sortedWarnings returns the warnings ordered by line, prefixed with the
+// file and line.
+func (mf *makefile) sortedWarnings() []string {
+	sort.SliceStable(mf.warnings, func(i, j int) bool {
+		return mf.warnings[i].line < mf.warnings[j].line
+	})
+
+	res := make([]string, len(mf.warnings))
+	for i, w := range mf.warnings {
+		res[i] = fmt.Sprintf("%s:%d: %s", mf.path, w.line, w.msg)
+	}
+	return res
+}
+
+func (mf *makefile) assign(name, op, value string, line int) {
+	existing := mf.vars[name]
+
+	switch op {
+	case "=":
+		mf.vars[name] = &makeVar{value: value}
+	case ":=", "::=":
+		mf.vars[name] = &makeVar{value: mf.expand(value, nil, true, line), simple: true}
+	case "?=":
+		if existing == nil {
+			mf.vars[name] = &makeVar{value: value, weak: true}
+		}
+	case "+=":
+		switch {
+		case existing == nil:
+			mf.vars[name] = &makeVar{value: value}
+		case existing.simple:
+			existing.value += " " + mf.expand(value, nil, true, line)
+		default:
+			existing.value += " " + value
+		}
+	case "!=":
+		mf.vars[name] = &makeVar{value: "$(" + mf.expand(value, nil, true, line) + ")", simple: true}
+	}
+}
+
+// parseRule parses the rule on line, returning the rules to which the recipe
+// lines that follow belong. Recipes of rules which are skipped are dropped
+// using an empty list.
+func (mf *makefile) parseRule(line, desc string, num int) []*makeRule {
+	if m := reDescSuffix.FindStringSubmatch(line); m != nil {
+		desc = m[1]
+		line = strings.TrimSpace(line[:len(line)-len(m[0])])
+	}
+	line = stripComment(line)
+
+	targetsPart, rest, _ := strings.Cut(line, ":")
+	if strings.HasPrefix(rest, ":") {
+		mf.warnf(num, "double-colon rule is translated as ordinary rule")
+		rest = rest[1:]
+	}
+
+	var inline string
+	if before, after, ok := strings.Cut(rest, ";"); ok {
+		rest, inline = before, strings.TrimSpace(after)
+	}
+
+	if strings.Contains(rest, "=") {
+		mf.warnf(num, "target-specific variable is not supported; skipped")
+		return []*makeRule{}
+	}
+
+	names := strings.Fields(mf.expand(targetsPart, nil, false, num))
+	prereqs := strings.Fields(strings.ReplaceAll(mf.expand(rest, nil, false, num), "|", " "))
+
+	rules := []*makeRule{}
+	for _, name := range names {
+		switch {
+		case name == ".PHONY":
+			for _, p := range prereqs {
+				mf.phony[p] = true
+			}
+			continue
+		case specialTargets[name]:
+			mf.warnf(num, "special target %s is not supported; skipped", name)
+			continue
+		case strings.Contains(name, "%"):
+			mf.warnf(num, "pattern rule %s is not supported; skipped", name)
+			continue
+		case strings.HasPrefix(name, ".") && !strings.ContainsAny(name, "/") && strings.Count(name, ".") == 2:
+			mf.warnf(num, "suffix rule %s is not supported; skipped", name)
+			continue
+		}
+
+		rule, ok := mf.byName[name]
+		if !ok {
+			rule = &makeRule{name: name, line: num}
+			mf.byName[name] = rule
+			mf.rules = append(mf.rules, rule)
+		} else if len(rule.recipe) > 0 && inline != "" {
+			mf.warnf(num, "recipe of %s given more than once; using the last", name)
+			rule.recipe = nil
+		}
+		if desc != "" {
+			rule.description = desc
+		}
+		rule.prereqs = append(rule.prereqs, prereqs...)
+		if inline != "" {
+			rule.recipe = append(rule.recipe, inline)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// expand expands variable references and functions in s. Recipes are
+// expanded for the shell: automatic variables of rule are substituted,
+// variables which can be overridden and unknown variables become shell
+// variables, and $(shell ...) becomes a command substitution. Otherwise,
+// values known when translating are used.
+func (mf *makefile) expand(s string, rule *makeRule, forShell bool, line int) string {
+	return mf.expandDepth(s, rule, forShell, line, 0)
+}
+
+func (mf *makefile) expandDepth(s string, rule *makeRule, forShell bool, line, depth int) string {
+	if depth > 20 {
+		mf.warnf(line, "variable references itself")
+		return ""
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		var ref string
+		switch s[i] {
+		case '$':
+			sb.WriteByte('$')
+			continue
+		case '(', '{':
+			end := matchingParen(s, i)
+			if end < 0 {
+				mf.warnf(line, "unterminated variable reference")
+				sb.WriteString(s[i-1:])
+				return sb.String()
+			}
+			ref = s[i+1 : end]
+			i = end
+		default:
+			ref = s[i : i+1]
+		}
+
+		sb.WriteString(mf.reference(ref, rule, forShell, line, depth))
+	}
+
+	return sb.String()
+}
+
+// matchingParen returns the index of the parenthesis or brace closing the
+// one at start, or -1.
+func matchingParen(s string, start int) int {
+	opening, closing := s[start], byte(')')
+	if opening == '{' {
+		closing = '}'
+	}
+
+	level := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case opening:
+			level++
+		case closing:
+			level--
+			if level == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (mf *makefile) reference(ref string, rule *makeRule, forShell bool, line, depth int) string {
+	if fn, args, ok := strings.Cut(ref, " "); ok {
+		args = mf.expandDepth(strings.TrimSpace(args), rule, true, line, depth+1)
+		switch {
+		case fn == "shell" && forShell:
+			return "$(" + args + ")"
+		case fn == "wildcard" && forShell:
+			// the shell expands the patterns
+			return args
+		case fn == "shell" || fn == "wildcard":
+			mf.warnf(line, "$(%s %s) is only supported in recipes; replaced by empty string", fn, args)
+			return ""
+		}
+		mf.warnf(line, "function %s is not supported; left as is", fn)
+		return "$(" + ref + ")"
+	}
+
+	if rule != nil {
+		if v, ok := automaticVar(ref, rule); ok {
+			return v
+		}
+	}
+
+	v, ok := mf.vars[ref]
+	switch {
+	case !ok && forShell:
+		return "${" + ref + "}"
+	case !ok:
+		return ""
+	case v.simple:
+		return v.value
+	}
+
+	value := mf.expandDepth(v.value, rule, forShell, line, depth+1)
+	if v.weak && forShell {
+		return "${" + ref + ":-" + value + "}"
+	}
+	return value
+}
+
+// automaticVar returns the value of the automatic variable ref within the
+// recipe of rule.
+func automaticVar(ref string, rule *makeRule) (string, bool) {
+	switch ref {
+	case "@":
+		return rule.name, true
+	case "<":
+		if len(rule.prereqs) > 0 {
+			return rule.prereqs[0], true
+		}
+		return "", true
+	case "^":
+		var uniq []string
+		seen := map[string]bool{}
+		for _, p := range rule.prereqs {
+			if !seen[p] {
+				seen[p] = true
+				uniq = append(uniq, p)
+			}
+		}
+		return strings.Join(uniq, " "), true
+	case "+":
+		return strings.Join(rule.prereqs, " "), true
+	case "@D":
+		return path.Dir(rule.name), true
+	case "@F":
+		return path.Base(rule.name), true
+	}
+	return "", false
+}
+
+// genMakeTarget describes a target translated from a rule.
+type genMakeTarget struct {
+	Var         string
+	Name        string
+	Description string
+	Recipe      []string
+	PreTargets  []string
+}
+
+// translate returns the targets for the rules of the Makefile.
+func (mf *makefile) translate() []genMakeTarget {
+	vars := map[string]string{}
+	used := map[string]bool{}
+	for _, rule := range mf.rules {
+		v := goVarName(rule.name)
+		for i := 2; used[v]; i++ {
+			v = goVarName(rule.name) + strconv.Itoa(i)
+		}
+		used[v] = true
+		vars[rule.name] = v
+	}
+
+	dropped := mf.dropCycles()
+
+	var targets []genMakeTarget
+	for _, rule := range mf.rules {
+		t := genMakeTarget{
+			Var:         vars[rule.name],
+			Name:        rule.name,
+			Description: rule.description,
+		}
+
+		seen := map[string]bool{}
+		for _, p := range rule.prereqs {
+			switch {
+			case seen[p] || dropped[[2]string{rule.name, p}]:
+				continue
+			case mf.byName[p] == nil:
+				mf.warnf(rule.line, "prerequisite %s of %s is not a rule; ignored", p, rule.name)
+				continue
+			}
+			seen[p] = true
+			t.PreTargets = append(t.PreTargets, vars[p])
+		}
+
+		for _, line := range rule.recipe {
+			t.Recipe = append(t.Recipe, mf.expand(line, rule, true, rule.line))
+		}
+
+		if !mf.phony[rule.name] && strings.ContainsAny(rule.name, "./") {
+			mf.warnf(rule.line, "%s is not .PHONY but is executed each time, even when the file is up to date", rule.name)
+		}
+
+		targets = append(targets, t)
+	}
+
+	return targets
+}
+
+// dropCycles returns the dependencies which are dropped because they are
+// circular, as make does.
+func (mf *makefile) dropCycles() map[[2]string]bool {
+	dropped := map[[2]string]bool{}
+	state := map[string]int{} // 1: visiting, 2: done
+
+	var visit func(rule *makeRule)
+	visit = func(rule *makeRule) {
+		state[rule.name] = 1
+		for _, p := range rule.prereqs {
+			dep := mf.byName[p]
+			if dep == nil {
+				continue
+			}
+			switch state[p] {
+			case 1:
+				mf.warnf(rule.line, "circular dependency %s <- %s dropped", rule.name, p)
+				dropped[[2]string{rule.name, p}] = true
+			case 0:
+				visit(dep)
+			}
+		}
+		state[rule.name] = 2
+	}
+
+	for _, rule := range mf.rules {
+		if state[rule.name] == 0 {
+			visit(rule)
+		}
+	}
+
+	return dropped
+}
+
+// goVarName returns the name of the variable holding the target name.
+func goVarName(name string) string {
+	var sb strings.Builder
+	sb.WriteString("target")
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func goString(s string) string {
+	if strconv.CanBackquote(s) && strings.ContainsAny(s, `"\`) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+var importTemplate = template.Must(template.New("import").Funcs(template.FuncMap{
+	"str": goString,
+}).Parse(`// Translated from {{ .Source }} by gomake import-makefile.
+{{- if .Warnings }}
+//
+// The following was not translated, or translated differently:
+//
+{{- range .Warnings }}
+//	{{ . }}
+{{- end }}
+{{- end }}
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/golistic/gomake"
+)
+
+var (
+{{- range .Targets }}
+	{{ .Var }} = gomake.NewTarget({{ str .Name }}, func(target *gomake.Target) error {
+{{- if .Recipe }}
+		return sh(target,
+{{- range .Recipe }}
+			{{ str . }},
+{{- end }}
+		)
+{{- else }}
+		return nil
+{{- end }}
+	}{{ if .Description }}, gomake.WithDescription({{ str .Description }}){{ end }}
+{{- if .PreTargets }}, gomake.WithPreTargets({{ range $i, $p := .PreTargets }}{{ if $i }}, {{ end }}{{ $p }}{{ end }}){{ end }})
+{{- end }}
+)
+
+func main() {
+	gomake.RegisterTargets(
+{{- range .Targets }}
+		{{ .Var }},
+{{- end }}
+	)
+	gomake.Make()
+}
+
+// sh runs the lines of a recipe using the shell, as make does. Lines starting
+// with @ are not shown, and failures of lines starting with - are ignored.
+func sh(target *gomake.Target, lines ...string) error {
+	for _, line := range lines {
+		silent, ignore := false, false
+		for line != "" && strings.ContainsRune("@-+", rune(line[0])) {
+			silent = silent || line[0] == '@'
+			ignore = ignore || line[0] == '-'
+			line = strings.TrimSpace(line[1:])
+		}
+
+		if !silent {
+			target.Maker.Log.Info(line)
+		}
+
+		cmd := exec.Command("sh", "-c", line)
+		cmd.Stdout = target.Maker.StdOut
+		cmd.Stderr = target.Maker.StdErr
+		if err := target.RunCmd(cmd); err != nil && !ignore {
+			return err
+		}
+	}
+	return nil
+}
+`))
+
+// render returns the source of the main package running the targets
+// translated from the Makefile.
+func (mf *makefile) render() ([]byte, error) {
+	targets := mf.translate()
+	if len(targets) == 0 {
+		return nil, errors.New("no rules found which can be translated")
+	}
+
+	var buf bytes.Buffer
+	err := importTemplate.Execute(&buf, map[string]any{
+		"Source":   filepath.Base(mf.path),
+		"Targets":  targets,
+		"Warnings": mf.sortedWarnings(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestImportMakefile(t *testing.T) {
+	dir := filepath.Join("testdata", "makefile")
+
+	data, err := os.ReadFile(filepath.Join(dir, "Makefile"))
+	xt.OK(t, err)
+
+	t.Run("matches committed file", func(t *testing.T) {
+		have, err := parseMakefile("Makefile", data).render()
+		xt.OK(t, err)
+
+		exp, err := os.ReadFile(filepath.Join(dir, "main.go"))
+		xt.OK(t, err)
+		xt.Eq(t, string(exp), string(have))
+	})
+
+	t.Run("expansion", func(t *testing.T) {
+		mf := parseMakefile("Makefile", []byte(`
+A = $(B) later
+B = b
+C := $(B) now
+C += more
+D ?= $(B)
+E != echo e
+build: x y x
+	echo $@ $< $^ $+ $(@F) $$D $(A) $(C) $(D) $(E) $(UNKNOWN)
+x:
+y:
+`))
+		targets := mf.translate()
+		xt.Eq(t, 3, len(targets))
+		xt.Eq(t, []string{`echo build x x y x y x build $D b later b now more ${D:-b} $(echo e) ${UNKNOWN}`},
+			targets[0].Recipe)
+		xt.Eq(t, []string{"targetX", "targetY"}, targets[0].PreTargets)
+		xt.Eq(t, 0, len(mf.warnings))
+	})
+
+	t.Run("descriptions", func(t *testing.T) {
+		mf := parseMakefile("Makefile", []byte(`
+# Builds the
+# application
+build:
+
+# not for test
+
+test: ## Runs tests
+`))
+		targets := mf.translate()
+		xt.Eq(t, "Builds the application", targets[0].Description)
+		xt.Eq(t, "Runs tests", targets[1].Description)
+	})
+
+	t.Run("command", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "cmd", "make", "main.go")
+
+		var stdOut, stdErr strings.Builder
+		args := []string{"import-makefile", "-f", filepath.Join(dir, "Makefile"), "-out", out}
+		xt.Eq(t, 0, run(args, &stdOut, &stdErr))
+		xt.Assert(t, strings.Contains(stdErr.String(),
+			filepath.Join(dir, "Makefile")+":38: pattern rule %.o is not supported; skipped\n"), stdErr.String())
+
+		_, err := os.Stat(out)
+		xt.OK(t, err)
+
+		stdErr.Reset()
+		xt.Eq(t, 1, run(args, &stdOut, &stdErr))
+		xt.Eq(t, "gomake import-makefile: "+out+" exists; use -force to overwrite\n", stdErr.String())
+
+		xt.Eq(t, 0, run(append(args, "-force"), &stdOut, &stdErr))
+	})
+
+	t.Run("generated code runs", func(t *testing.T) {
+		cmd := exec.Command("go", "run", "./"+filepath.ToSlash(dir), "-log-dir", "", "help")
+		out, err := cmd.CombinedOutput()
+		xt.OK(t, err, string(out))
+		xt.Assert(t, strings.Contains(string(out), "   build\n      Build the application\n"), string(out))
+
+		cmd = exec.Command("go", "run", "./"+filepath.ToSlash(dir), "-log-dir", "", "loop-a")
+		out, err = cmd.CombinedOutput()
+		xt.OK(t, err, string(out))
+	})
+}
@@ -0,0 +1,36 @@
+// Code generated by gomake gen; DO NOT EDIT.
+
+package main
+
+import (
+	"time"
+
+	"github.com/golistic/gomake"
+)
+
+type gomakeBuildHTTPServerFlags struct {
+	Tag       string        `flag:"tag"`
+	NoCache   bool          `flag:"no-cache"`
+	Platforms []string      `flag:"platforms"`
+	Timeout   time.Duration `flag:"timeout"`
+}
+
+type gomakeReleaseFlags struct {
+	Retries  int `flag:"retries"`
+	Parallel int `flag:"parallel"`
+}
+
+func init() {
+	gomake.RegisterTargets(
+		gomake.NewTarget("clean", func(target *gomake.Target) error {
+			Clean()
+			return nil
+		}, gomake.WithDescription("Clean removes build artifacts.")),
+		gomake.NewTypedTarget("build-http-server", func(target *gomake.Target, flags *gomakeBuildHTTPServerFlags) error {
+			return BuildHTTPServer(target, flags.Tag, flags.NoCache, flags.Platforms, flags.Timeout)
+		}, gomake.WithDescription("BuildHTTPServer builds the server for the given platforms.")),
+		gomake.NewTypedTarget("release", func(target *gomake.Target, flags *gomakeReleaseFlags) error {
+			return Release(flags.Retries, flags.Parallel)
+		}, gomake.WithDescription("Release creates a release.")),
+	)
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	gm "github.com/golistic/gomake"
+)
+
+//go:generate go run github.com/golistic/gomake/cmd/gomake gen
+
+func main() {
+	gm.Make()
+}
+
+// Clean removes build artifacts. It is safe to run
+// at any time.
+//
+// More details are not part of the description.
+func Clean() {}
+
+// BuildHTTPServer builds the server for the given platforms.
+func BuildHTTPServer(target *gm.Target, tag string, noCache bool, platforms []string, timeout time.Duration) error {
+	target.Maker.Log.Info("building", tag, noCache, platforms, timeout)
+	return nil
+}
+
+// Release creates a release.
+func Release(retries, parallel int) error {
+	return fmt.Errorf("retries %d, parallel %d", retries, parallel)
+}
+
+// Skipped is not a target since it returns a string.
+func Skipped() string { return "" }
+
+// Ignored is not a target.
+//
+// gomake:ignore
+func Ignored() {}
+
+func unexported() {}
+
+var _ = unexported
@@ -0,0 +1,109 @@
+// Translated from Makefile by gomake import-makefile.
+//
+// The following was not translated, or translated differently:
+//
+//	Makefile:20: prerequisite api.proto of generate is not a rule; ignored
+//	Makefile:30: conditional ifeq is not evaluated; using its first branch
+//	Makefile:38: pattern rule %.o is not supported; skipped
+//	Makefile:41: dist/app.tar.gz is not .PHONY but is executed each time, even when the file is up to date
+//	Makefile:45: circular dependency loop-b <- loop-a dropped
+//	Makefile:47: include extra.mk is not supported; skipped
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/golistic/gomake"
+)
+
+var (
+	targetAll = gomake.NewTarget("all", func(target *gomake.Target) error {
+		return nil
+	}, gomake.WithDescription("Build and test"), gomake.WithPreTargets(targetBuild, targetTest))
+	targetBuild = gomake.NewTarget("build", func(target *gomake.Target) error {
+		return sh(target,
+			`@echo "building bin/app ${VERSION:-dev}"`,
+			`go build -trimpath -v -ldflags "-X main.version=${VERSION:-dev} -X main.commit=$(git rev-parse --short HEAD)" -o bin/app ./cmd/app`,
+		)
+	}, gomake.WithDescription("Build the application"), gomake.WithPreTargets(targetGenerate))
+	targetGenerate = gomake.NewTarget("generate", func(target *gomake.Target) error {
+		return sh(target,
+			"protoc --go_out=. api.proto",
+		)
+	})
+	targetTest = gomake.NewTarget("test", func(target *gomake.Target) error {
+		return sh(target,
+			"go test -count=1 ./... ${EXTRA}",
+			`echo "home is $HOME, built $(date +%F)"`,
+		)
+	})
+	targetClean = gomake.NewTarget("clean", func(target *gomake.Target) error {
+		return sh(target,
+			"-rm -rf bin",
+		)
+	})
+	targetLint = gomake.NewTarget("lint", func(target *gomake.Target) error {
+		return sh(target,
+			"golangci-lint run --out-format github-actions",
+		)
+	})
+	targetDistAppTarGz = gomake.NewTarget("dist/app.tar.gz", func(target *gomake.Target) error {
+		return sh(target,
+			"tar czf dist/app.tar.gz bin",
+		)
+	}, gomake.WithPreTargets(targetBuild))
+	targetLoopA = gomake.NewTarget("loop-a", func(target *gomake.Target) error {
+		return nil
+	}, gomake.WithPreTargets(targetLoopB))
+	targetLoopB = gomake.NewTarget("loop-b", func(target *gomake.Target) error {
+		return nil
+	})
+	targetFiles = gomake.NewTarget("files", func(target *gomake.Target) error {
+		return sh(target,
+			"echo *.go",
+		)
+	})
+)
+
+func main() {
+	gomake.RegisterTargets(
+		targetAll,
+		targetBuild,
+		targetGenerate,
+		targetTest,
+		targetClean,
+		targetLint,
+		targetDistAppTarGz,
+		targetLoopA,
+		targetLoopB,
+		targetFiles,
+	)
+	gomake.Make()
+}
+
+// sh runs the lines of a recipe using the shell, as make does. Lines starting
+// with @ are not shown, and failures of lines starting with - are ignored.
+func sh(target *gomake.Target, lines ...string) error {
+	for _, line := range lines {
+		silent, ignore := false, false
+		for line != "" && strings.ContainsRune("@-+", rune(line[0])) {
+			silent = silent || line[0] == '@'
+			ignore = ignore || line[0] == '-'
+			line = strings.TrimSpace(line[1:])
+		}
+
+		if !silent {
+			target.Maker.Log.Info(line)
+		}
+
+		cmd := exec.Command("sh", "-c", line)
+		cmd.Stdout = target.Maker.StdOut
+		cmd.Stderr = target.Maker.StdErr
+		if err := target.RunCmd(cmd); err != nil && !ignore {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSkip is returned, possibly wrapped, by Do to report the target was
+// skipped. Unlike a failure, it does not stop the run.
+var ErrSkip = errors.New("skipped")
+
+// Seq returns a target executing targets one after the other, stopping at
+// the first which fails. Its name is derived from the names of targets, and
+// can be changed before registering it.
+func Seq(targets ...*Target) *Target {
+	return &Target{
+		Name:       combinedName("seq", targets),
+		children:   targets,
+		combinator: "seq",
+		Do: func(target *Target) error {
+			for _, child := range target.children {
+				if target.Maker.runTarget(child, target.report.lane) > 0 {
+					return fmt.Errorf("%s: target %s failed", target.Name, child.Name)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// Par returns a target executing targets in parallel, failing when any of
+// them fails. The output of targets is shown as it is produced, and is stored
+// in the log file of the returned target.
+func Par(targets ...*Target) *Target {
+	return &Target{
+		Name:       combinedName("par", targets),
+		children:   targets,
+		combinator: "par",
+		Do: func(target *Target) error {
+			m := target.Maker
+
+			atomic.AddInt32(&m.parallel, 1)
+			defer atomic.AddInt32(&m.parallel, -1)
+
+			exitCodes := make([]int, len(target.children))
+			var wg sync.WaitGroup
+			for i, child := range target.children {
+				// all but the first target get a lane of their own in the trace
+				lane := target.report.lane
+				if i > 0 {
+					lane = int(atomic.AddInt32(&m.lanes, 1))
+				}
+
+				wg.Add(1)
+				go func(i int, child *Target, lane int) {
+					defer wg.Done()
+					exitCodes[i] = m.runTarget(child, lane)
+				}(i, child, lane)
+			}
+			wg.Wait()
+
+			var failed []string
+			for i, code := range exitCodes {
+				if code > 0 {
+					failed = append(failed, target.children[i].Name)
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("%s: failed targets: %s", target.Name, strings.Join(failed, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+// OnlyIf returns a target executing t only when pred, called with the
+// returned target, reports true. Otherwise, the returned target is skipped
+// without stopping the run.
+func OnlyIf(pred func(target *Target) bool, t *Target) *Target {
+	return &Target{
+		Name:       "only-if(" + t.Name + ")",
+		children:   []*Target{t},
+		combinator: "only-if",
+		Do: func(target *Target) error {
+			if !pred(target) {
+				return fmt.Errorf("%s: condition not met: %w", target.Name, ErrSkip)
+			}
+			if target.Maker.runTarget(target.children[0], target.report.lane) > 0 {
+				return fmt.Errorf("%s: target %s failed", target.Name, target.children[0].Name)
+			}
+			return nil
+		},
+	}
+}
+
+// Finally returns a target executing t followed by cleanup, which are
+// executed even when t fails. The returned target fails when any of them
+// fails.
+func Finally(t *Target, cleanup ...*Target) *Target {
+	return &Target{
+		Name:       combinedName("finally", append([]*Target{t}, cleanup...)),
+		children:   append([]*Target{t}, cleanup...),
+		combinator: "finally",
+		Do: func(target *Target) error {
+			var failed []string
+			for _, child := range target.children {
+				if target.Maker.runTarget(child, target.report.lane) > 0 {
+					failed = append(failed, child.Name)
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("%s: failed targets: %s", target.Name, strings.Join(failed, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+func combinedName(kind string, targets []*Target) string {
+	names := make([]string, len(targets))
+	for i, t := range targets {
+		names[i] = t.Name
+	}
+	return kind + "(" + strings.Join(names, ",") + ")"
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golistic/xt"
+)
+
+func TestCombinators(t *testing.T) {
+	type recorder struct {
+		mu    sync.Mutex
+		names []string
+	}
+
+	newTarget := func(rec *recorder, name string, err error) *Target {
+		return &Target{
+			Name: name,
+			Do: func(target *Target) error {
+				rec.mu.Lock()
+				rec.names = append(rec.names, name)
+				rec.mu.Unlock()
+				return err
+			},
+		}
+	}
+
+	newMaker := func() *Maker {
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.StdErr = &strings.Builder{}
+		return m
+	}
+
+	statuses := func(m *Maker) map[string]TargetStatus {
+		res := map[string]TargetStatus{}
+		for _, tr := range m.Report().Targets {
+			res[tr.Name] = tr.Status
+		}
+		return res
+	}
+
+	t.Run("seq stops at first failure", func(t *testing.T) {
+		rec := &recorder{}
+		seq := Seq(newTarget(rec, "a", nil), newTarget(rec, "b", errors.New("failed")), newTarget(rec, "c", nil))
+		xt.Eq(t, "seq(a,b,c)", seq.Name)
+
+		m := newMaker()
+		m.registerTargets(seq)
+
+		xt.Eq(t, 1, m.make("seq(a,b,c)"))
+		xt.Eq(t, []string{"a", "b"}, rec.names)
+		xt.Eq(t, map[string]TargetStatus{
+			"seq(a,b,c)": StatusFailed, "a": StatusSucceeded, "b": StatusFailed,
+		}, statuses(m))
+	})
+
+	t.Run("par runs targets concurrently", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		meet := func(target *Target) error {
+			wg.Done()
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("other target did not run concurrently")
+			}
+		}
+
+		par := Par(&Target{Name: "a", Do: meet}, &Target{Name: "b", Do: meet})
+		par.Name = "all"
+
+		m := newMaker()
+		m.registerTargets(par)
+
+		xt.Eq(t, 0, m.make("all"))
+		xt.Eq(t, map[string]TargetStatus{
+			"all": StatusSucceeded, "a": StatusSucceeded, "b": StatusSucceeded,
+		}, statuses(m))
+	})
+
+	t.Run("targets run once per run", func(t *testing.T) {
+		rec := &recorder{}
+		shared := newTarget(rec, "shared", nil)
+		a := newTarget(rec, "a", nil)
+		a.PreTargets = []*Target{shared}
+		b := newTarget(rec, "b", nil)
+		b.PreTargets = []*Target{shared}
+
+		m := newMaker()
+		m.registerTargets(Seq(Par(a, b), shared))
+
+		xt.Eq(t, 0, m.make("seq(par(a,b),shared)"))
+		xt.Eq(t, 1, strings.Count(strings.Join(rec.names, " "), "shared"))
+		xt.Eq(t, 5, len(m.Report().Targets))
+
+		// a new run executes targets again
+		xt.Eq(t, 0, m.make("seq(par(a,b),shared)"))
+		xt.Eq(t, 2, strings.Count(strings.Join(rec.names, " "), "shared"))
+	})
+
+	t.Run("deferred targets run again", func(t *testing.T) {
+		rec := &recorder{}
+		stop := newTarget(rec, "stop", nil)
+		test := newTarget(rec, "test", nil)
+		test.PreTargets = []*Target{stop}
+		test.DeferredTargets = []*Target{stop}
+
+		m := newMaker()
+		m.registerTargets(test)
+
+		xt.Eq(t, 0, m.make("test"))
+		xt.Eq(t, []string{"stop", "test", "stop"}, rec.names)
+	})
+
+	t.Run("cycles are reported", func(t *testing.T) {
+		rec := &recorder{}
+		a := newTarget(rec, "a", nil)
+		b := newTarget(rec, "b", nil)
+		a.PreTargets = []*Target{b}
+		b.DeferredTargets = []*Target{Seq(a)}
+
+		m := newMaker()
+		stdErr := &strings.Builder{}
+		m.StdErr = stdErr
+		m.registerTargets(a)
+
+		xt.Eq(t, 1, m.make("a"))
+		xt.Assert(t, strings.Contains(stdErr.String(), "dependency cycle: a -> b -> seq(a) -> a"), stdErr.String())
+		xt.Eq(t, 0, len(rec.names))
+	})
+
+	t.Run("only-if", func(t *testing.T) {
+		rec := &recorder{}
+		cond := false
+		onlyIf := OnlyIf(func(*Target) bool { return cond }, newTarget(rec, "deploy", nil))
+		seq := Seq(onlyIf, newTarget(rec, "after", nil))
+
+		m := newMaker()
+		m.registerTargets(seq)
+
+		xt.Eq(t, 0, m.make(seq.Name))
+		xt.Eq(t, []string{"after"}, rec.names)
+		xt.Eq(t, StatusSkipped, statuses(m)["only-if(deploy)"])
+
+		cond = true
+		xt.Eq(t, 0, m.make(seq.Name))
+		xt.Eq(t, []string{"after", "deploy", "after"}, rec.names)
+	})
+
+	t.Run("finally runs cleanup when target fails", func(t *testing.T) {
+		rec := &recorder{}
+		finally := Finally(newTarget(rec, "test", errors.New("failed")), newTarget(rec, "cleanup", nil))
+
+		m := newMaker()
+		m.registerTargets(finally)
+
+		xt.Eq(t, 1, m.make("finally(test,cleanup)"))
+		xt.Eq(t, []string{"test", "cleanup"}, rec.names)
+		xt.Eq(t, StatusFailed, statuses(m)["finally(test,cleanup)"])
+	})
+
+	t.Run("clone copies combined targets", func(t *testing.T) {
+		rec := &recorder{}
+		seq := Seq(newTarget(rec, "a", nil))
+		clone := seq.Clone()
+		clone.children[0].Name = "b"
+
+		m := newMaker()
+		m.registerTargets(clone)
+		xt.Eq(t, 0, m.make("seq(a)"))
+		xt.Eq(t, "a", seq.children[0].Name)
+		xt.Eq(t, []string{"a"}, rec.names) // Do of the original is kept
+		xt.Eq(t, StatusSucceeded, statuses(m)["b"])
+	})
+}
+
+func TestCombinators_trace(t *testing.T) {
+	noop := func(name string) *Target {
+		return &Target{Name: name, Do: func(*Target) error { return nil }}
+	}
+
+	b := noop("b")
+	b.PreTargets = []*Target{noop("pre-of-b")}
+	seq := Seq(Par(noop("a"), b, noop("c")), noop("after"))
+
+	m := NewMaker()
+	m.StdOut = &strings.Builder{}
+	m.StdErr = &strings.Builder{}
+	m.registerTargets(seq)
+	xt.Eq(t, 0, m.make(seq.Name))
+
+	data, err := m.Report().ChromeTrace()
+	xt.OK(t, err)
+
+	var trace traceFile
+	xt.OK(t, json.Unmarshal(data, &trace))
+
+	lanes := map[string]int{}
+	for _, ev := range trace.TraceEvents {
+		lanes[ev.Name] = ev.TID
+	}
+	xt.Eq(t, map[string]int{
+		"seq(par(a,b,c),after)": 1, "par(a,b,c)": 1, "a": 1,
+		"b": lanes["b"], "pre-of-b": lanes["b"], "c": lanes["c"], "after": 1,
+	}, lanes)
+	xt.Assert(t, lanes["b"] > 1 && lanes["c"] > 1 && lanes["b"] != lanes["c"], "b and c must have lanes of their own")
+}
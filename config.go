@@ -0,0 +1,556 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// ConfigFileNames are the names of configuration files looked for, in order,
+// when walking up from the working directory.
+var ConfigFileNames = []string{"gomake.json", "gomake.toml", "gomake.yaml", "gomake.yml"}
+
+// Config is the project configuration providing defaults for targets.
+type Config struct {
+	// Path is the file from which the configuration was loaded.
+	Path    string
+	Targets map[string]*TargetConfig
+	// Profiles are named sets of defaults applied on top of Targets when
+	// selected using the global -profile option.
+	Profiles map[string]*Profile
+}
+
+// Profile holds defaults of targets which are used together, for example
+// when making a release.
+type Profile struct {
+	Targets map[string]*TargetConfig
+}
+
+// TargetConfig holds the defaults of a single target.
+type TargetConfig struct {
+	// Flags maps names of flags, as used on the command line, to values.
+	Flags map[string]any
+	// Settings are merged with the settings of the target.
+	Settings map[string]any
+
+	// Run holds the shell commands of a target defined in the configuration
+	// file; see Maker.defineTargets. The other fields below can only be
+	// used together with Run.
+	Run []string
+	// Description is shown in help.
+	Description string
+	// Deps are names of targets executed before the commands.
+	Deps []string
+	// Env holds variables added to the environment of the commands.
+	Env map[string]string
+	// Dir is the directory, relative to the configuration file, in which
+	// the commands are run.
+	Dir string
+	// Inputs and Outputs are glob patterns of files, relative to Dir. When
+	// both are given, commands are only run when an output is missing or
+	// older than one of the inputs.
+	Inputs  []string
+	Outputs []string
+
+	key string
+}
+
+// defines returns whether tc defines a target rather than configuring an
+// existing one.
+func (tc *TargetConfig) defines() bool {
+	return tc.Run != nil || tc.Description != "" || tc.Deps != nil || tc.Env != nil ||
+		tc.Dir != "" || tc.Inputs != nil || tc.Outputs != nil
+}
+
+// ConfigError is returned when the configuration file is invalid.
+type ConfigError struct {
+	Path string
+	Key  string
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%s: %s", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Path, e.Key, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// FindConfigFile walks up from dir looking for one of ConfigFileNames. It
+// returns an empty string when none is found.
+func FindConfigFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		for _, name := range ConfigFileNames {
+			p := filepath.Join(dir, name)
+			if _, err := os.Stat(p); err == nil {
+				return p, nil
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return "", err
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig reads the configuration file at path. Files with extension
+// `.toml` are parsed as TOML v1.0 using github.com/BurntSushi/toml, with
+// `.yaml` or `.yml` as YAML using gopkg.in/yaml.v3, all others as JSON.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	switch filepath.Ext(path) {
+	case ".toml":
+		raw, err = parseTOML(data)
+	case ".yaml", ".yml":
+		raw, err = parseYAML(data)
+	default:
+		err = json.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, &ConfigError{Path: path, Err: err}
+	}
+
+	return decodeConfig(path, raw)
+}
+
+func decodeConfig(path string, raw map[string]any) (*Config, error) {
+	cfg := &Config{
+		Path:     path,
+		Targets:  map[string]*TargetConfig{},
+		Profiles: map[string]*Profile{},
+	}
+
+	for _, key := range sortedKeys(raw) {
+		value := raw[key]
+		switch key {
+		case "targets":
+			targets, err := cfg.decodeTargets(key, value, true)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Targets = targets
+		case "profiles":
+			profiles, err := cfg.decodeProfiles(key, value)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Profiles = profiles
+		default:
+			return nil, cfg.errorf(key, "unknown key")
+		}
+	}
+
+	return cfg, nil
+}
+
+func (cfg *Config) decodeProfiles(key string, value any) (map[string]*Profile, error) {
+	sections, ok := value.(map[string]any)
+	if !ok {
+		return nil, cfg.errorf(key, "must be a table of profiles")
+	}
+
+	profiles := map[string]*Profile{}
+
+	for _, name := range sortedKeys(sections) {
+		profileKey := key + "." + name
+		fields, ok := sections[name].(map[string]any)
+		if !ok {
+			return nil, cfg.errorf(profileKey, "must be a table")
+		}
+
+		profile := &Profile{Targets: map[string]*TargetConfig{}}
+		for _, field := range sortedKeys(fields) {
+			if field != "targets" {
+				return nil, cfg.errorf(profileKey+"."+field, "unknown key")
+			}
+			targets, err := cfg.decodeTargets(profileKey+"."+field, fields[field], false)
+			if err != nil {
+				return nil, err
+			}
+			profile.Targets = targets
+		}
+		profiles[name] = profile
+	}
+
+	return profiles, nil
+}
+
+// decodeTargets decodes the table of targets found at key. Targets can be
+// defined only when definitions is true.
+func (cfg *Config) decodeTargets(key string, value any, definitions bool) (map[string]*TargetConfig, error) {
+	sections, ok := value.(map[string]any)
+	if !ok {
+		return nil, cfg.errorf(key, "must be a table of targets")
+	}
+
+	targets := map[string]*TargetConfig{}
+
+	for _, name := range sortedKeys(sections) {
+		fields, ok := sections[name].(map[string]any)
+		if !ok {
+			return nil, cfg.errorf(key+"."+name, "must be a table")
+		}
+
+		tc := &TargetConfig{key: key + "." + name}
+		for _, field := range sortedKeys(fields) {
+			if err := cfg.decodeTargetField(tc, field, fields[field]); err != nil {
+				return nil, err
+			}
+		}
+
+		if tc.defines() {
+			switch {
+			case !definitions:
+				return nil, cfg.errorf(tc.key, "targets can only be defined in the targets table")
+			case tc.Run == nil:
+				return nil, cfg.errorf(tc.key, "run is required to define a target")
+			}
+		}
+		targets[name] = tc
+	}
+
+	return targets, nil
+}
+
+func (cfg *Config) errorf(key, format string, a ...any) error {
+	return &ConfigError{Path: cfg.Path, Key: key, Err: fmt.Errorf(format, a...)}
+}
+
+func (cfg *Config) decodeTargetField(tc *TargetConfig, field string, v any) error {
+	fieldKey := tc.key + "." + field
+
+	var err error
+	switch field {
+	case "flags", "settings":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return cfg.errorf(fieldKey, "must be a table")
+		}
+		if field == "flags" {
+			tc.Flags = m
+		} else {
+			tc.Settings = m
+		}
+	case "run":
+		tc.Run, err = cfg.stringList(fieldKey, v)
+	case "description":
+		tc.Description, err = cfg.string(fieldKey, v)
+	case "deps":
+		tc.Deps, err = cfg.stringList(fieldKey, v)
+	case "env":
+		tc.Env, err = cfg.stringMap(fieldKey, v)
+	case "dir":
+		tc.Dir, err = cfg.string(fieldKey, v)
+	case "inputs":
+		tc.Inputs, err = cfg.stringList(fieldKey, v)
+	case "outputs":
+		tc.Outputs, err = cfg.stringList(fieldKey, v)
+	default:
+		return cfg.errorf(fieldKey, "unknown key")
+	}
+
+	return err
+}
+
+func (cfg *Config) string(key string, v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", cfg.errorf(key, "must be a string")
+	}
+	return s, nil
+}
+
+// stringList returns v as list of strings; a single string is a list with
+// one element.
+func (cfg *Config) stringList(key string, v any) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		res := make([]string, len(v))
+		for i, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, cfg.errorf(fmt.Sprintf("%s[%d]", key, i), "must be a string")
+			}
+			res[i] = s
+		}
+		return res, nil
+	default:
+		return nil, cfg.errorf(key, "must be a list of strings")
+	}
+}
+
+// stringMap returns v as map of strings. Numbers and booleans are converted
+// to strings, so that environment variables such as `CGO_ENABLED = 0` can be
+// written without quotes.
+func (cfg *Config) stringMap(key string, v any) (map[string]string, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, cfg.errorf(key, "must be a table")
+	}
+
+	res := make(map[string]string, len(m))
+	for _, k := range sortedKeys(m) {
+		s, err := flagValue(m[k])
+		if err != nil {
+			return nil, cfg.errorf(key+"."+k, "must be a string")
+		}
+		res[k] = s
+	}
+	return res, nil
+}
+
+// Validate checks the configuration against targets: each configured target
+// must exist, its flags must be defined by the target and have valid
+// values, and settings must match the type of the settings of the target.
+// Targets configured in profiles are checked likewise.
+func (cfg *Config) Validate(targets map[string]*Target) error {
+	if err := cfg.validateTargets(cfg.Targets, targets); err != nil {
+		return err
+	}
+
+	for _, name := range sortedKeys(cfg.Profiles) {
+		if err := cfg.validateTargets(cfg.Profiles[name].Targets, targets); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cfg *Config) validateTargets(configs map[string]*TargetConfig, targets map[string]*Target) error {
+	for _, name := range sortedKeys(configs) {
+		tc := configs[name]
+
+		target, ok := targets[name]
+		if !ok {
+			target, ok = matchPattern(targets, name)
+		}
+		if !ok {
+			return cfg.errorf(tc.key, "unknown target")
+		}
+
+		if _, err := cfg.flagArgs(target, tc); err != nil {
+			return err
+		}
+		if _, err := cfg.settings(target.Settings, tc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// targetConfigs returns the configurations of the targets names, first the
+// defaults and then those of profile. Configurations of names given later
+// take precedence.
+func (cfg *Config) targetConfigs(profile string, names ...string) []*TargetConfig {
+	var res []*TargetConfig
+	for _, name := range names {
+		if tc, ok := cfg.Targets[name]; ok {
+			res = append(res, tc)
+		}
+	}
+	if p, ok := cfg.Profiles[profile]; ok {
+		for _, name := range names {
+			if tc, ok := p.Targets[name]; ok {
+				res = append(res, tc)
+			}
+		}
+	}
+	return res
+}
+
+// flagArgs returns the flags configured for target as command line arguments.
+func (cfg *Config) flagArgs(target *Target, tc *TargetConfig) ([]string, error) {
+	if tc == nil || len(tc.Flags) == 0 {
+		return nil, nil
+	}
+
+	flagSet := target.FlagSet()
+	flagSet.SetOutput(io.Discard)
+
+	var args []string
+	for _, name := range sortedKeys(tc.Flags) {
+		key := tc.key + ".flags." + name
+
+		if flagSet.Lookup(name) == nil {
+			return nil, cfg.errorf(key, "flag not defined by target")
+		}
+
+		value, err := flagValue(tc.Flags[name])
+		if err != nil {
+			return nil, cfg.errorf(key, "%s", err)
+		}
+
+		if err := flagSet.Set(name, value); err != nil {
+			return nil, cfg.errorf(key, "invalid value %q: %s", value, err)
+		}
+
+		args = append(args, "-"+name+"="+value)
+	}
+
+	return args, nil
+}
+
+// settings returns base merged with the settings of tc. Configured values are
+// converted to the type of the existing setting.
+func (cfg *Config) settings(base map[string]any, tc *TargetConfig) (map[string]any, error) {
+	if tc == nil || len(tc.Settings) == 0 {
+		return base, nil
+	}
+
+	res := map[string]any{}
+	for k, v := range base {
+		res[k] = v
+	}
+
+	for _, name := range sortedKeys(tc.Settings) {
+		key := tc.key + ".settings." + name
+		value := tc.Settings[name]
+
+		if current, ok := base[name]; ok && current != nil {
+			converted, err := convertTo(reflect.TypeOf(current), value)
+			if err != nil {
+				return nil, cfg.errorf(key, "must be of type %T", current)
+			}
+			value = converted
+		}
+
+		res[name] = value
+	}
+
+	return res, nil
+}
+
+// flagValue returns value as it would be given on the command line. Floats
+// are formatted without exponent, as JSON decodes all numbers as float64.
+func flagValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool, int64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// convertTo converts value, as decoded from JSON, TOML or YAML, to typ.
+func convertTo(typ reflect.Type, value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	ptr := reflect.New(typ)
+	if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return nil, err
+	}
+
+	return ptr.Elem().Interface(), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// loadConfig loads and validates the configuration file of the Maker.
+func (m *Maker) loadConfig() error {
+	m.config = nil
+	m.undefineTargets()
+	if m.ConfigFile == "" {
+		return nil
+	}
+
+	cfg, err := LoadConfig(m.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	if err := m.defineTargets(cfg); err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(m.allTargets()); err != nil {
+		return err
+	}
+
+	m.config = cfg
+	return nil
+}
+
+// checkProfile returns an error when the profile of the Maker is not defined
+// in the configuration file.
+func (m *Maker) checkProfile() error {
+	switch {
+	case m.Profile == "":
+		return nil
+	case m.config == nil:
+		return fmt.Errorf("profile %s given but no configuration file found", m.Profile)
+	}
+
+	if _, ok := m.config.Profiles[m.Profile]; !ok {
+		return m.config.errorf("profiles."+m.Profile, "profile not defined")
+	}
+	return nil
+}
+
+// allTargets returns registered targets and the targets they depend on by
+// their name.
+func (m *Maker) allTargets() map[string]*Target {
+	res := map[string]*Target{}
+
+	var walk func(targets []*Target)
+	walk = func(targets []*Target) {
+		for _, t := range targets {
+			if _, ok := res[t.Name]; ok {
+				continue
+			}
+			res[t.Name] = t
+			walk(t.PreTargets)
+			walk(t.DeferredTargets)
+			walk(t.children)
+		}
+	}
+
+	for _, name := range sortedKeys(m.targetRegistry) {
+		walk([]*Target{m.targetRegistry[name]})
+	}
+
+	return res
+}
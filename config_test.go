@@ -0,0 +1,500 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestParseTOML(t *testing.T) {
+	t.Run("documents", func(t *testing.T) {
+		doc := `
+# comment
+title = "gomake" # trailing comment
+
+[targets.docker-buildx.flags]
+registry = "ghcr.io/org"
+"no-cache" = true
+retries = 1_000
+ratio = 0.5
+esc = "tab\tquote\" \u00e9"
+lit = 'C:\path'
+
+[targets."go-coverage"]
+settings.integration = [
+  ["go", "version"], # first
+  ["go", "env"],
+]
+inline = { a = 1, b.c = "d" }
+multi = """
+first
+second"""
+`
+		have, err := parseTOML([]byte(doc))
+		xt.OK(t, err)
+
+		exp := map[string]any{
+			"title": "gomake",
+			"targets": map[string]any{
+				"docker-buildx": map[string]any{
+					"flags": map[string]any{
+						"registry": "ghcr.io/org",
+						"no-cache": true,
+						"retries":  int64(1000),
+						"ratio":    0.5,
+						"esc":      "tab\tquote\" é",
+						"lit":      `C:\path`,
+					},
+				},
+				"go-coverage": map[string]any{
+					"settings": map[string]any{
+						"integration": []any{[]any{"go", "version"}, []any{"go", "env"}},
+					},
+					"inline": map[string]any{"a": int64(1), "b": map[string]any{"c": "d"}},
+					"multi":  "first\nsecond",
+				},
+			},
+		}
+		xt.Eq(t, exp, have)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		cases := map[string]string{
+			"a = 1\na = 2":  "line 2",
+			"a = \"open":    "line 1",
+			"a = 1 b = 2":   "line 1",
+			"\n\na = [1, 2": "line 3",
+			"a = 1\n[a]":    "line 2",
+		}
+
+		for doc, exp := range cases {
+			_, err := parseTOML([]byte(doc))
+			xt.KO(t, err)
+			xt.Assert(t, strings.Contains(err.Error(), exp), err.Error())
+		}
+	})
+}
+
+func TestParseYAML(t *testing.T) {
+	t.Run("documents", func(t *testing.T) {
+		doc := `---
+# comment
+title: gomake # trailing comment
+targets:
+  docker-buildx:
+    flags:
+      registry: ghcr.io/org
+      "no-cache": true
+      retries: 1000
+      ratio: 0.5
+      esc: "tab\tquote\" #"
+      lit: 'it''s C:\path'
+      empty:
+  proto:
+    run:
+    - protoc --go_out=. api.proto
+    - 'echo "a: b"' # quoted, as plain scalars cannot contain ": "
+    deps: [tools, "go-version"]
+    env: {GOFLAGS: -mod=mod, CGO_ENABLED: 0}
+    script: |
+      set -e
+      go test ./...
+
+    folded: >-
+      one
+      two
+
+      three
+    list:
+      - name: a
+        args:
+          - x
+      - - nested
+    anchored: &flags {a: 1}
+    alias: *flags
+`
+		have, err := parseYAML([]byte(doc))
+		xt.OK(t, err)
+
+		exp := map[string]any{
+			"title": "gomake",
+			"targets": map[string]any{
+				"docker-buildx": map[string]any{
+					"flags": map[string]any{
+						"registry": "ghcr.io/org",
+						"no-cache": true,
+						"retries":  int64(1000),
+						"ratio":    0.5,
+						"esc":      "tab\tquote\" #",
+						"lit":      `it's C:\path`,
+						"empty":    nil,
+					},
+				},
+				"proto": map[string]any{
+					"run":    []any{"protoc --go_out=. api.proto", `echo "a: b"`},
+					"deps":   []any{"tools", "go-version"},
+					"env":    map[string]any{"GOFLAGS": "-mod=mod", "CGO_ENABLED": int64(0)},
+					"script": "set -e\ngo test ./...\n",
+					"folded": "one two\nthree",
+					"list": []any{
+						map[string]any{"name": "a", "args": []any{"x"}},
+						[]any{"nested"},
+					},
+					"anchored": map[string]any{"a": int64(1)},
+					"alias":    map[string]any{"a": int64(1)},
+				},
+			},
+		}
+		xt.Eq(t, exp, have)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		cases := map[string]string{
+			"a: 1\na: 2":    "line 2: mapping key \"a\" already defined",
+			"a: 1\n   b: 2": "line 2",
+			"a: [1, 2":      "line 1",
+			"a: *ref":       "unknown anchor",
+			"- a":           "document must be a mapping",
+			"just text":     "document must be a mapping",
+		}
+
+		for doc, exp := range cases {
+			_, err := parseYAML([]byte(doc))
+			xt.KO(t, err)
+			xt.Assert(t, strings.Contains(err.Error(), exp), err.Error())
+		}
+	})
+}
+
+func TestConfig(t *testing.T) {
+	newTarget := func() *Target {
+		return &Target{
+			Name: "configured",
+			Settings: map[string]any{
+				"commands": [][]string{{"go", "version"}},
+			},
+			DefineFlags: func(flagSet *flag.FlagSet) {
+				flagSet.String("image", "", "Docker image")
+				flagSet.Bool("no-cache", false, "Do not use cache")
+			},
+			HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+				flagSet, err := target.ParseFlags()
+				if err != nil {
+					return nil, err
+				}
+				target.StoreFlag(flagSet, "image", "image")
+				target.StoreFlag(flagSet, "no-cache", "noCache")
+				return flagSet, nil
+			},
+		}
+	}
+
+	writeConfig := func(t *testing.T, name, content string) string {
+		p := filepath.Join(t.TempDir(), name)
+		xt.OK(t, os.WriteFile(p, []byte(content), 0o600))
+		return p
+	}
+
+	t.Run("find configuration walking up", func(t *testing.T) {
+		root := t.TempDir()
+		sub := filepath.Join(root, "a", "b")
+		xt.OK(t, os.MkdirAll(sub, 0o700))
+
+		have, err := FindConfigFile(sub)
+		xt.OK(t, err)
+		xt.Eq(t, "", have)
+
+		exp := filepath.Join(root, "gomake.toml")
+		xt.OK(t, os.WriteFile(exp, nil, 0o600))
+		have, err = FindConfigFile(sub)
+		xt.OK(t, err)
+		xt.Eq(t, exp, have)
+	})
+
+	t.Run("flags and settings are applied", func(t *testing.T) {
+		configFile := writeConfig(t, "gomake.toml", `
+[targets.configured.flags]
+image = "from-config"
+no-cache = true
+
+[targets.configured.settings]
+commands = [["go", "env"]]
+extra = "value"
+`)
+		for _, c := range []struct {
+			args     []string
+			expImage string
+		}{
+			{args: []string{}, expImage: "from-config"},
+			{args: []string{"-image", "from-cmdline"}, expImage: "from-cmdline"},
+		} {
+			var have *Target
+			target := newTarget()
+			target.Flags = map[string]any{"image": "from-code"}
+			target.Do = func(target *Target) error {
+				cp := *target
+				have = &cp
+				return nil
+			}
+
+			m := NewMaker()
+			m.StdOut = &strings.Builder{}
+			m.ConfigFile = configFile
+			m.registerTargets(target)
+
+			xt.Eq(t, 0, m.make(append([]string{"configured"}, c.args...)...))
+			xt.Eq(t, c.expImage, have.Flags["image"])
+			xt.Eq(t, true, have.Flags["noCache"])
+			xt.Eq(t, [][]string{{"go", "env"}}, have.Settings["commands"])
+			xt.Eq(t, "value", have.Settings["extra"])
+
+			// target itself is left untouched
+			xt.Eq(t, [][]string{{"go", "version"}}, target.Settings["commands"])
+			xt.Eq(t, c.args, target.FlagArgs)
+		}
+	})
+
+	t.Run("large numbers in JSON", func(t *testing.T) {
+		configFile := writeConfig(t, "gomake.json", `{
+  "targets": {
+    "counted": {"flags": {"count": 1000000, "ratio": 0.25}},
+    "defined": {"run": "true", "env": {"LIMIT": 1000000}}
+  }
+}`)
+		var haveCount int
+		var haveRatio float64
+		target := &Target{
+			Name: "counted",
+			DefineFlags: func(flagSet *flag.FlagSet) {
+				flagSet.Int("count", 0, "Count")
+				flagSet.Float64("ratio", 0, "Ratio")
+			},
+			Do: func(target *Target) error {
+				flagSet, err := target.ParseFlags()
+				if err != nil {
+					return err
+				}
+				haveCount = flagSet.Lookup("count").Value.(flag.Getter).Get().(int)
+				haveRatio = flagSet.Lookup("ratio").Value.(flag.Getter).Get().(float64)
+				return nil
+			},
+		}
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.ConfigFile = configFile
+		m.registerTargets(target)
+
+		xt.Eq(t, 0, m.make("counted"))
+		xt.Eq(t, 1000000, haveCount)
+		xt.Eq(t, 0.25, haveRatio)
+
+		cfg, err := LoadConfig(configFile)
+		xt.OK(t, err)
+		xt.Eq(t, map[string]string{"LIMIT": "1000000"}, cfg.Targets["defined"].Env)
+	})
+
+	t.Run("errors name file and key", func(t *testing.T) {
+		cases := []struct {
+			name    string
+			content string
+			exp     string
+		}{
+			{
+				name:    "gomake.json",
+				content: `{"target": {}}`,
+				exp:     `target: unknown key`,
+			},
+			{
+				name:    "gomake.json",
+				content: `{"targets": {"unknown": {}}}`,
+				exp:     `targets.unknown: unknown target`,
+			},
+			{
+				name:    "gomake.json",
+				content: `{"targets": {"configured": {"flags": {"imgae": "x"}}}}`,
+				exp:     `targets.configured.flags.imgae: flag not defined by target`,
+			},
+			{
+				name:    "gomake.toml",
+				content: "[targets.configured.flags]\nno-cache = \"maybe\"",
+				exp:     `targets.configured.flags.no-cache: invalid value "maybe": parse error`,
+			},
+			{
+				name:    "gomake.toml",
+				content: "[targets.configured.settings]\ncommands = \"go version\"",
+				exp:     `targets.configured.settings.commands: must be of type [][]string`,
+			},
+			{
+				name:    "gomake.toml",
+				content: "[targets.configured.flags]\nimage = [1]",
+				exp:     `targets.configured.flags.image: unsupported value [1]`,
+			},
+		}
+
+		for _, c := range cases {
+			t.Run(c.exp, func(t *testing.T) {
+				configFile := writeConfig(t, c.name, c.content)
+
+				var bufErr strings.Builder
+				m := NewMaker()
+				m.StdErr = &bufErr
+				m.ConfigFile = configFile
+				m.registerTargets(newTarget())
+
+				xt.Eq(t, 1, m.make("configured"))
+				xt.Eq(t, "Error: "+configFile+": "+c.exp+"\n", bufErr.String())
+
+				_, err := LoadConfig(configFile)
+				if err == nil {
+					err = m.loadConfig()
+				}
+				var errConfig *ConfigError
+				xt.Assert(t, errors.As(err, &errConfig))
+				xt.Eq(t, configFile, errConfig.Path)
+			})
+		}
+	})
+}
+
+func TestConfig_Profiles(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "gomake.toml")
+	xt.OK(t, os.WriteFile(configFile, []byte(`
+[targets.profiled.flags]
+registry = "localhost:5000"
+platform = "linux/amd64"
+
+[profiles.release.targets.profiled.flags]
+registry = "ghcr.io/org"
+platform = "linux/amd64,linux/arm64"
+
+[profiles.release.targets.profiled.settings]
+push = true
+`), 0o600))
+
+	var have *Target
+	newMaker := func(stdErr *strings.Builder) *Maker {
+		target := &Target{
+			Name: "profiled",
+			DefineFlags: func(flagSet *flag.FlagSet) {
+				flagSet.String("registry", "", "Docker registry")
+				flagSet.String("platform", "", "Platforms")
+			},
+			HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+				flagSet, err := target.ParseFlags()
+				if err != nil {
+					return nil, err
+				}
+				target.StoreFlag(flagSet, "registry", "registry")
+				target.StoreFlag(flagSet, "platform", "platform")
+				return flagSet, nil
+			},
+			Do: func(target *Target) error {
+				cp := *target
+				have = &cp
+				return nil
+			},
+		}
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.StdErr = stdErr
+		m.ConfigFile = configFile
+		m.registerTargets(target)
+		return m
+	}
+
+	cases := []struct {
+		name        string
+		profile     string
+		args        []string
+		expRegistry string
+		expPlatform string
+	}{
+		{
+			name:        "no profile",
+			expRegistry: "localhost:5000",
+			expPlatform: "linux/amd64",
+		},
+		{
+			name:        "global option",
+			profile:     "release",
+			expRegistry: "ghcr.io/org",
+			expPlatform: "linux/amd64,linux/arm64",
+		},
+		{
+			name:        "after target",
+			args:        []string{"--profile", "release", "-platform", "linux/arm64"},
+			expRegistry: "ghcr.io/org",
+			expPlatform: "linux/arm64",
+		},
+		{
+			name:        "after target using equal sign",
+			args:        []string{"-profile=release"},
+			expRegistry: "ghcr.io/org",
+			expPlatform: "linux/amd64,linux/arm64",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			have = nil
+			m := newMaker(&strings.Builder{})
+			m.Profile = c.profile
+
+			xt.Eq(t, 0, m.make(append([]string{"profiled"}, c.args...)...))
+			xt.Eq(t, c.expRegistry, have.Flags["registry"])
+			xt.Eq(t, c.expPlatform, have.Flags["platform"])
+			if m.Profile == "release" {
+				xt.Eq(t, true, have.Settings["push"])
+			}
+		})
+	}
+
+	t.Run("profile flag of HandleFlags", func(t *testing.T) {
+		var haveProfile string
+		m := newMaker(&strings.Builder{})
+		m.registerTargets(&Target{
+			Name: "own-profile",
+			HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+				flagSet := flag.NewFlagSet(target.Name, flag.ContinueOnError)
+				flagSet.StringVar(&haveProfile, "profile", "", "Profile of the target")
+				return flagSet, flagSet.Parse(target.FlagArgs)
+			},
+			Do: func(target *Target) error { return nil },
+		})
+
+		xt.Eq(t, 0, m.make("own-profile", "-profile", "staging"))
+		xt.Eq(t, "staging", haveProfile)
+		xt.Eq(t, "", m.Profile)
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		var bufErr strings.Builder
+		m := newMaker(&bufErr)
+
+		xt.Eq(t, 1, m.make("profiled", "--profile", "staging"))
+		xt.Eq(t, "Error: "+configFile+": profiles.staging: profile not defined\n", bufErr.String())
+	})
+
+	t.Run("invalid profile is reported", func(t *testing.T) {
+		p := filepath.Join(t.TempDir(), "gomake.json")
+		xt.OK(t, os.WriteFile(p,
+			[]byte(`{"profiles": {"release": {"targets": {"profiled": {"flags": {"tga": "1"}}}}}}`), 0o600))
+
+		var bufErr strings.Builder
+		m := newMaker(&bufErr)
+		m.ConfigFile = p
+
+		xt.Eq(t, 1, m.make("profiled"))
+		xt.Eq(t, "Error: "+p+": profiles.release.targets.profiled.flags.tga: flag not defined by target\n",
+			bufErr.String())
+	})
+}
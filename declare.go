@@ -0,0 +1,189 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// defineTargets registers the targets defined in the configuration file.
+// Targets registered in Go cannot be redefined, but plugins with the same
+// name are replaced. Dependencies can be targets defined in Go or in the
+// configuration file.
+func (m *Maker) defineTargets(cfg *Config) error {
+	var names []string
+	for _, name := range sortedKeys(cfg.Targets) {
+		tc := cfg.Targets[name]
+		if !tc.defines() {
+			continue
+		}
+		if registered, ok := m.targetRegistry[name]; ok {
+			if registered.plugin == "" {
+				return cfg.errorf(tc.key, "target already registered")
+			}
+			m.replaced = append(m.replaced, registered)
+			delete(m.targetRegistry, name)
+		}
+		names = append(names, name)
+	}
+
+	dir := filepath.Dir(cfg.Path)
+	for _, name := range names {
+		m.registerTargets(newDefinedTarget(name, cfg.Targets[name], dir))
+		m.defined = append(m.defined, name)
+	}
+
+	for _, name := range names {
+		tc := cfg.Targets[name]
+		target := m.targetRegistry[name]
+		for i, dep := range tc.Deps {
+			pre, ok := m.lookupTarget(dep)
+			if !ok {
+				return cfg.errorf(fmt.Sprintf("%s.deps[%d]", tc.key, i), "unknown target %s", dep)
+			}
+			target.PreTargets = append(target.PreTargets, pre)
+		}
+	}
+
+	return nil
+}
+
+// undefineTargets removes the targets registered by defineTargets, and
+// restores the plugins they replaced, so that each run uses the targets of
+// its configuration file.
+func (m *Maker) undefineTargets() {
+	for _, name := range m.defined {
+		delete(m.targetRegistry, name)
+	}
+	m.defined = nil
+
+	for _, target := range m.replaced {
+		m.targetRegistry[target.Name] = target
+	}
+	m.replaced = nil
+}
+
+// newDefinedTarget returns the target defined by tc, whose commands run in
+// the directory of the configuration file unless tc sets one. Templates in
+// commands are expanded.
+func newDefinedTarget(name string, tc *TargetConfig, configDir string) *Target {
+	workDir := tc.Dir
+	if !filepath.IsAbs(workDir) {
+		workDir = filepath.Join(configDir, workDir)
+	}
+	run, inputs, outputs := tc.Run, tc.Inputs, tc.Outputs
+
+	return &Target{
+		Name:        name,
+		Description: tc.Description,
+		WorkDir:     workDir,
+		Env:         Env{Set: tc.Env},
+		Do: func(target *Target) error {
+			if len(inputs) > 0 && len(outputs) > 0 {
+				upToDate, err := target.upToDate(inputs, outputs)
+				if err != nil {
+					return err
+				}
+				if upToDate {
+					return fmt.Errorf("%s: outputs are up to date: %w", target.Name, ErrSkip)
+				}
+			}
+
+			for _, line := range run {
+				line, err := target.Expand(line)
+				if err != nil {
+					return fmt.Errorf("%s: %w", target.Name, err)
+				}
+
+				target.Maker.Log.Info("running:", line)
+				cmd := shellCommand(line)
+				cmd.Stdout = target.Maker.StdOut
+				cmd.Stderr = target.Maker.StdErr
+				if err := target.RunCmd(cmd); err != nil {
+					return fmt.Errorf("%s: %s: %w", target.Name, line, err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// shellCommand returns the command running line using the shell of the
+// operating system.
+func shellCommand(line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", line)
+	}
+	return exec.Command("sh", "-c", line)
+}
+
+// upToDate returns whether all files matching outputs exist and are not
+// older than any of the files matching inputs. Patterns are relative to the
+// work directory of the target, and directories match the files they hold.
+func (t *Target) upToDate(inputs, outputs []string) (bool, error) {
+	var newestInput time.Time
+	for _, pattern := range inputs {
+		modTimes, err := t.modTimes(pattern)
+		if err != nil {
+			return false, err
+		}
+		if len(modTimes) == 0 {
+			return false, fmt.Errorf("%s: no files match input %s", t.Name, pattern)
+		}
+		for _, mt := range modTimes {
+			if mt.After(newestInput) {
+				newestInput = mt
+			}
+		}
+	}
+
+	for _, pattern := range outputs {
+		modTimes, err := t.modTimes(pattern)
+		if err != nil {
+			return false, err
+		}
+		if len(modTimes) == 0 {
+			return false, nil
+		}
+		for _, mt := range modTimes {
+			if mt.Before(newestInput) {
+				return false, nil
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// modTimes returns the modification times of the files matching pattern.
+func (t *Target) modTimes(pattern string) ([]time.Time, error) {
+	matches, err := filepath.Glob(t.Path(pattern))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.Name, err)
+	}
+
+	var res []time.Time
+	for _, match := range matches {
+		err := filepath.WalkDir(match, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			res = append(res, info.ModTime())
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s: %w", t.Name, err)
+		}
+	}
+	return res, nil
+}
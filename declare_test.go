@@ -0,0 +1,138 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golistic/xt"
+)
+
+func TestMaker_definedTargets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands use a POSIX shell")
+	}
+
+	newMaker := func(t *testing.T, config string) (*Maker, *strings.Builder, string) {
+		t.Helper()
+		dir := t.TempDir()
+		p := filepath.Join(dir, "gomake.yaml")
+		xt.OK(t, os.WriteFile(p, []byte(config), 0o600))
+
+		m := NewMaker()
+		stdOut := &strings.Builder{}
+		m.StdOut = stdOut
+		m.StdErr = &strings.Builder{}
+		m.LogDir = ""
+		m.Log.Level = LevelWarn
+		m.ConfigFile = p
+		return m, stdOut, dir
+	}
+
+	t.Run("commands, deps, env and dir", func(t *testing.T) {
+		m, stdOut, dir := newMaker(t, `
+targets:
+  tools:
+    run: echo tools
+  build:
+    description: Builds everything
+    deps: [tools, version]
+    dir: sub
+    env:
+      GREETING: hello
+    run:
+      - echo "$GREETING from $(basename $(pwd))"
+      - echo "{{ .Target.Name }}"
+`)
+		xt.OK(t, os.Mkdir(filepath.Join(dir, "sub"), 0o700))
+		m.registerTargets(NewTarget("version", func(target *Target) error {
+			_, _ = target.Maker.StdOut.Write([]byte("version\n"))
+			return nil
+		}))
+
+		xt.Eq(t, 0, m.make("build"))
+		xt.Eq(t, "tools\nversion\nhello from sub\nbuild\n", stdOut.String())
+		xt.Eq(t, 2, len(m.Report().Targets[0].Commands))
+
+		stdOut.Reset()
+		xt.Eq(t, 0, m.make("help"))
+		xt.Assert(t, strings.Contains(stdOut.String(), "   build\n      Builds everything\n"), stdOut.String())
+	})
+
+	t.Run("failing command", func(t *testing.T) {
+		m, _, _ := newMaker(t, `
+targets:
+  fail:
+    run: [exit 3, echo never]
+`)
+		xt.Eq(t, 1, m.make("fail"))
+		tr := m.Report().Targets[0]
+		xt.Eq(t, StatusFailed, tr.Status)
+		xt.Eq(t, "fail: exit 3: exit status 3", tr.Error)
+	})
+
+	t.Run("inputs and outputs", func(t *testing.T) {
+		m, _, dir := newMaker(t, `
+targets:
+  gen:
+    inputs: ["*.proto"]
+    outputs: [api.pb.go]
+    run: cat api.proto > api.pb.go
+`)
+		proto := filepath.Join(dir, "api.proto")
+		xt.OK(t, os.WriteFile(proto, []byte("syntax"), 0o600))
+
+		xt.Eq(t, 0, m.make("gen"))
+		xt.Eq(t, StatusSucceeded, m.Report().Targets[0].Status)
+
+		xt.Eq(t, 0, m.make("gen"))
+		tr := m.Report().Targets[0]
+		xt.Eq(t, StatusSkipped, tr.Status)
+		xt.Eq(t, "gen: outputs are up to date: skipped", tr.Error)
+
+		later := time.Now().Add(time.Minute)
+		xt.OK(t, os.Chtimes(proto, later, later))
+		xt.Eq(t, 0, m.make("gen"))
+		xt.Eq(t, StatusSucceeded, m.Report().Targets[0].Status)
+	})
+
+	t.Run("targets are replaced each run", func(t *testing.T) {
+		m, _, _ := newMaker(t, "targets:\n  a:\n    run: 'true'\n")
+		xt.Eq(t, 0, m.make("a"))
+		xt.Eq(t, 0, m.make("a"))
+
+		m.ConfigFile = ""
+		xt.Eq(t, 1, m.make("a"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		cases := map[string]string{
+			"targets:\n  a:\n    run: 'true'\n    deps: [missing]":     "targets.a.deps[0]: unknown target missing",
+			"targets:\n  a:\n    description: no commands":             "targets.a: run is required to define a target",
+			"targets:\n  a:\n    run: [1]":                             "targets.a.run[0]: must be a string",
+			"targets:\n  go:\n    run: 'true'":                         "targets.go: target already registered",
+			"profiles:\n  ci:\n    targets:\n      a:\n        run: x": "profiles.ci.targets.a: targets can only be defined in the targets table",
+		}
+
+		for config, exp := range cases {
+			t.Run(exp, func(t *testing.T) {
+				m, _, _ := newMaker(t, config)
+				m.registerTargets(NewTarget("go", nil))
+				stdErr := &strings.Builder{}
+				m.StdErr = stdErr
+
+				xt.Eq(t, 1, m.make("go"))
+				var cfgErr *ConfigError
+				err := m.loadConfig()
+				xt.Assert(t, errors.As(err, &cfgErr))
+				xt.Eq(t, exp, cfgErr.Key+": "+cfgErr.Err.Error())
+			})
+		}
+	})
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Env defines the environment of the commands run by a target. By default,
+// commands inherit the environment of the process.
+type Env struct {
+	// Set holds variables which are added, or replace inherited ones.
+	Set map[string]string
+	// Unset holds names of variables which are removed.
+	Unset []string
+	// Hermetic, when true, does not inherit variables except those
+	// named in Allow.
+	Hermetic bool
+	// Allow holds the names of variables inherited in hermetic mode. A name
+	// ending with `*` matches all variables starting with what precedes it.
+	Allow []string
+}
+
+// environ returns the environment starting from base, which is a list of
+// strings of the form "key=value" like os.Environ returns.
+func (e Env) environ(base []string) []string {
+	vars := newEnvVars()
+
+	for _, kv := range base {
+		k, v, _ := strings.Cut(kv, "=")
+		if e.Hermetic && !e.allowed(k) {
+			continue
+		}
+		vars.set(k, v)
+	}
+
+	for _, k := range e.Unset {
+		vars.unset(k)
+	}
+
+	keys := make([]string, 0, len(e.Set))
+	for k := range e.Set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		vars.set(k, e.Set[k])
+	}
+
+	return vars.list()
+}
+
+func (e Env) allowed(name string) bool {
+	for _, a := range e.Allow {
+		if prefix, ok := strings.CutSuffix(a, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Environ returns the environment of the commands run by the target, as a list
+// of strings of the form "key=value". Variables loaded by the Maker from
+// environment files are included.
+func (t *Target) Environ() []string {
+	if t.Maker != nil {
+		return t.Env.environ(t.Maker.environ())
+	}
+	return t.Env.environ(os.Environ())
+}
+
+// environ returns the environment of the target with overlay, a list of
+// strings of the form "key=value", added.
+func (t *Target) environ(overlay []string) []string {
+	vars := newEnvVars()
+	for _, kv := range t.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		vars.set(k, v)
+	}
+	for _, kv := range overlay {
+		k, v, _ := strings.Cut(kv, "=")
+		vars.set(k, v)
+	}
+
+	return vars.list()
+}
+
+// envVars keeps environment variables in the order they were first set.
+type envVars struct {
+	names  []string
+	values map[string]string
+}
+
+func newEnvVars() *envVars {
+	return &envVars{values: map[string]string{}}
+}
+
+func (ev *envVars) set(name, value string) {
+	if _, ok := ev.values[name]; !ok {
+		ev.names = append(ev.names, name)
+	}
+	ev.values[name] = value
+}
+
+func (ev *envVars) unset(name string) {
+	if _, ok := ev.values[name]; !ok {
+		return
+	}
+	delete(ev.values, name)
+	for i, n := range ev.names {
+		if n == name {
+			ev.names = append(ev.names[:i], ev.names[i+1:]...)
+			break
+		}
+	}
+}
+
+func (ev *envVars) list() []string {
+	res := make([]string, 0, len(ev.names))
+	for _, n := range ev.names {
+		res = append(res, n+"="+ev.values[n])
+	}
+	return res
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestEnv(t *testing.T) {
+	base := []string{"HOME=/home/alice", "GOPATH=/go", "GOFLAGS=-mod=mod", "TOKEN=secret"}
+
+	t.Run("inherit by default", func(t *testing.T) {
+		xt.Eq(t, base, Env{}.environ(base))
+	})
+
+	t.Run("additions and removals", func(t *testing.T) {
+		env := Env{
+			Set:   map[string]string{"GOPATH": "/tmp/go", "CGO_ENABLED": "0"},
+			Unset: []string{"TOKEN", "NOT_SET"},
+		}
+		xt.Eq(t, []string{"HOME=/home/alice", "GOPATH=/tmp/go", "GOFLAGS=-mod=mod", "CGO_ENABLED=0"},
+			env.environ(base))
+	})
+
+	t.Run("hermetic with allowlist", func(t *testing.T) {
+		env := Env{
+			Hermetic: true,
+			Allow:    []string{"HOME", "GO*"},
+			Unset:    []string{"GOFLAGS"},
+			Set:      map[string]string{"CGO_ENABLED": "0"},
+		}
+		xt.Eq(t, []string{"HOME=/home/alice", "GOPATH=/go", "CGO_ENABLED=0"}, env.environ(base))
+	})
+}
+
+func TestTarget_RunCmd(t *testing.T) {
+	newMakerFor := func(target *Target) {
+		target.Maker = NewMaker()
+		target.Maker.StdOut = &strings.Builder{}
+	}
+
+	t.Run("environment of target and command", func(t *testing.T) {
+		target := &Target{
+			Name: "env",
+			Env: Env{
+				Set: map[string]string{"GOPRIVATE": "example.com/target", "GONOPROXY": "example.com/target"},
+			},
+		}
+		newMakerFor(target)
+
+		var buf strings.Builder
+		cmd := exec.Command("go", "env", "GOPRIVATE", "GONOPROXY")
+		cmd.Env = []string{"GONOPROXY=example.com/cmd"}
+		cmd.Stdout = &buf
+		xt.OK(t, target.RunCmd(cmd))
+		xt.Eq(t, "example.com/target\nexample.com/cmd\n", buf.String())
+	})
+
+	t.Run("work directory", func(t *testing.T) {
+		dir := t.TempDir()
+		xt.OK(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/wd\n"), 0o600))
+
+		target := &Target{Name: "wd", WorkDir: dir}
+		newMakerFor(target)
+
+		var buf strings.Builder
+		cmd := exec.Command("go", "env", "GOMOD")
+		cmd.Stdout = &buf
+		xt.OK(t, target.RunCmd(cmd))
+		xt.Eq(t, filepath.Join(dir, "go.mod"), strings.TrimSpace(buf.String()))
+
+		xt.Eq(t, filepath.Join(dir, "vendor"), target.Path("vendor"))
+		xt.Eq(t, "/abs/vendor", target.Path("/abs/vendor"))
+	})
+
+	t.Run("standard input", func(t *testing.T) {
+		gofmt, err := exec.LookPath("gofmt")
+		if err != nil {
+			t.Skip("the 'gofmt' command is not available in PATH")
+		}
+
+		target := &Target{Name: "stdin", Stdin: strings.NewReader("package x;func  f(){}")}
+		newMakerFor(target)
+
+		var buf strings.Builder
+		cmd := exec.Command(gofmt)
+		cmd.Stdout = &buf
+		xt.OK(t, target.RunCmd(cmd))
+		xt.Eq(t, "package x\n\nfunc f() {}\n", buf.String())
+	})
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultEnvFile is the name of the file from which variables are loaded
+// when no environment files are given. It is looked for next to the
+// configuration file, or in the working directory.
+const DefaultEnvFile = ".env"
+
+// LoadEnvFile reads variables from the .env style file at path. Each line
+// holds a variable as `NAME=value`, optionally preceded by `export`. Values
+// can be quoted: within double quotes escape sequences such as `\n` are
+// interpreted, within single quotes nothing is. Unquoted and double-quoted
+// values can refer to other variables using `$NAME` or `${NAME}`. Lines
+// starting with `#` are comments.
+func LoadEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vars, err := parseEnvFile(string(data), os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", path, err)
+	}
+	return vars, nil
+}
+
+// parseEnvFile parses the content of a .env style file. Variables referred to
+// which are not defined earlier in the file are looked up using lookupEnv.
+func parseEnvFile(content string, lookupEnv func(string) (string, bool)) (map[string]string, error) {
+	vars := map[string]string{}
+
+	expand := func(s string) string {
+		return os.Expand(s, func(name string) string {
+			if v, ok := vars[name]; ok {
+				return v
+			}
+			v, _ := lookupEnv(name)
+			return v
+		})
+	}
+
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !isEnvName(name) {
+			return nil, fmt.Errorf("%d: expected NAME=value", i+1)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, `"`):
+			s, err := unquoteEnvValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("%d: %s: %w", i+1, name, err)
+			}
+			value = expand(s)
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("%d: %s: unterminated quoted value", i+1, name)
+			}
+			value = value[1 : end+1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+			value = expand(value)
+		}
+
+		vars[name] = value
+	}
+
+	return vars, nil
+}
+
+// unquoteEnvValue returns the double-quoted value with escape sequences
+// interpreted; anything following the closing quote is ignored.
+func unquoteEnvValue(value string) (string, error) {
+	var sb strings.Builder
+
+	for i := 1; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"':
+			return sb.String(), nil
+		case c == '\\' && i+1 < len(value):
+			i++
+			switch value[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			default:
+				sb.WriteByte(value[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return "", errors.New("unterminated quoted value")
+}
+
+func isEnvName(s string) bool {
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// loadEnvFiles loads the environment files of the Maker. When none are given,
+// DefaultEnvFile is loaded when it exists.
+func (m *Maker) loadEnvFiles() error {
+	m.env = map[string]string{}
+
+	paths := m.EnvFiles
+	if len(paths) == 0 {
+		dir := "."
+		if m.ConfigFile != "" {
+			dir = filepath.Dir(m.ConfigFile)
+		}
+		p := filepath.Join(dir, DefaultEnvFile)
+		if _, err := os.Stat(p); err != nil {
+			return nil
+		}
+		paths = []string{p}
+	}
+
+	for _, p := range paths {
+		vars, err := LoadEnvFile(p)
+		if err != nil {
+			return err
+		}
+		for k, v := range vars {
+			m.env[k] = v
+		}
+	}
+
+	return nil
+}
+
+// lookupEnv returns the value of the environment variable name. Variables of
+// the process take precedence over those loaded from environment files.
+func (m *Maker) lookupEnv(name string) (string, bool) {
+	if v, ok := os.LookupEnv(name); ok {
+		return v, true
+	}
+	v, ok := m.env[name]
+	return v, ok
+}
+
+func (m *Maker) getenv(name string) string {
+	v, _ := m.lookupEnv(name)
+	return v
+}
+
+// environ returns the environment of the process with the variables loaded
+// from environment files added.
+func (m *Maker) environ() []string {
+	res := os.Environ()
+	for _, name := range sortedKeys(m.env) {
+		if _, ok := os.LookupEnv(name); !ok {
+			res = append(res, name+"="+m.env[name])
+		}
+	}
+	return res
+}
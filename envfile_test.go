@@ -0,0 +1,123 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	t.Run("syntax", func(t *testing.T) {
+		content := `
+# comment
+PLAIN=value
+export EXPORTED = spaced value # comment
+DOUBLE="line\nnext \"quoted\" $PLAIN"
+SINGLE='no $PLAIN \n'
+EXPANDED=${PLAIN}-${FROM_PROCESS}
+EMPTY=
+`
+		lookup := func(name string) (string, bool) {
+			if name == "FROM_PROCESS" {
+				return "process", true
+			}
+			return "", false
+		}
+
+		have, err := parseEnvFile(content, lookup)
+		xt.OK(t, err)
+		xt.Eq(t, map[string]string{
+			"PLAIN":    "value",
+			"EXPORTED": "spaced value",
+			"DOUBLE":   "line\nnext \"quoted\" value",
+			"SINGLE":   `no $PLAIN \n`,
+			"EXPANDED": "value-process",
+			"EMPTY":    "",
+		}, have)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		cases := map[string]string{
+			"A=1\nno equal sign": "2: expected NAME=value",
+			"1A=1":               "1: expected NAME=value",
+			`A="open`:            "1: A: unterminated quoted value",
+			`A='open`:            "1: A: unterminated quoted value",
+		}
+
+		for content, exp := range cases {
+			_, err := parseEnvFile(content, os.LookupEnv)
+			xt.KO(t, err)
+			xt.Eq(t, exp, err.Error())
+		}
+	})
+
+	t.Run("error names file", func(t *testing.T) {
+		p := filepath.Join(t.TempDir(), ".env")
+		xt.OK(t, os.WriteFile(p, []byte("A=1\nB"), 0o600))
+
+		_, err := LoadEnvFile(p)
+		xt.KO(t, err)
+		xt.Eq(t, p+":2: expected NAME=value", err.Error())
+	})
+}
+
+func TestMaker_EnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "ci.env")
+	xt.OK(t, os.WriteFile(envFile, []byte(
+		"GOPRIVATE=example.com/envfile\nGONOPROXY=example.com/envfile\nGOMAKE_ENV_FILE_TAG=from-file\n"), 0o600))
+
+	t.Setenv("GONOPROXY", "example.com/process")
+
+	var haveTag any
+	var buf strings.Builder
+	target := &Target{
+		Name: "env-file",
+		DefineFlags: func(flagSet *flag.FlagSet) {
+			flagSet.String("tag", "", "Image tag")
+		},
+		HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+			flagSet, err := target.ParseFlags()
+			if err != nil {
+				return nil, err
+			}
+			target.StoreFlag(flagSet, "tag", "tag")
+			return flagSet, nil
+		},
+		Do: func(target *Target) error {
+			haveTag = target.Flags["tag"]
+
+			cmd := exec.Command("go", "env", "GOPRIVATE", "GONOPROXY")
+			cmd.Stdout = &buf
+			return target.RunCmd(cmd)
+		},
+	}
+
+	m := NewMaker()
+	m.StdOut = &strings.Builder{}
+	m.EnvFiles = []string{envFile}
+	m.registerTargets(target)
+
+	xt.Eq(t, 0, m.make("env-file"))
+	xt.Eq(t, "from-file", haveTag)
+	// variables of the process take precedence
+	xt.Eq(t, "example.com/envfile\nexample.com/process\n", buf.String())
+
+	t.Run("missing file", func(t *testing.T) {
+		var bufErr strings.Builder
+		m := NewMaker()
+		m.StdErr = &bufErr
+		m.EnvFiles = []string{filepath.Join(dir, "missing.env")}
+		m.registerTargets(&Target{Name: "noop", Do: func(*Target) error { return nil }})
+
+		xt.Eq(t, 1, m.make("noop"))
+		xt.Assert(t, strings.Contains(bufErr.String(), "missing.env"))
+	})
+}
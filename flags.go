@@ -0,0 +1,237 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FlagEnvPrefix is the prefix of the names of environment variables which
+// set the value of target flags.
+const FlagEnvPrefix = "GOMAKE"
+
+// FlagSet returns a new flag set, named after the target, with the flags of
+// the target defined. Parsing errors are returned, so that they are
+// reported like any other failure of the target.
+func (t *Target) FlagSet() *flag.FlagSet {
+	flagSet := flag.NewFlagSet(t.Name, flag.ContinueOnError)
+	if t.DefineFlags != nil {
+		t.DefineFlags(flagSet)
+	}
+	return flagSet
+}
+
+// knownFlagSet returns the flags of the target known to the Maker: those
+// defined by DefineFlags or, when the target only has HandleFlags, those of
+// the flag set HandleFlags returns when given no arguments. HandleFlags is
+// then called on a copy of the target, so that its flags are left untouched.
+func (t *Target) knownFlagSet() *flag.FlagSet {
+	if t.DefineFlags != nil || t.HandleFlags == nil {
+		return t.FlagSet()
+	}
+
+	probe := *t
+	probe.FlagArgs = nil
+	probe.Flags = cloneValue(t.Flags)
+	flagSet, err := probe.HandleFlags(&probe)
+	if err != nil || flagSet == nil {
+		return t.FlagSet()
+	}
+	return flagSet
+}
+
+// ParseFlags parses the flag arguments of the target using the flag set
+// returned by FlagSet. It makes sure Flags is not nil, so that handlers can
+// store values.
+func (t *Target) ParseFlags() (*flag.FlagSet, error) {
+	if t.Flags == nil {
+		t.Flags = map[string]any{}
+	}
+
+	flagSet := t.FlagSet()
+	if t.Maker != nil {
+		flagSet.SetOutput(t.Maker.StdErr)
+	}
+	if err := flagSet.Parse(t.FlagArgs); err != nil {
+		return nil, err
+	}
+
+	return flagSet, nil
+}
+
+// StoreFlag stores the value of the flag name in Flags using key. A value
+// given as argument overrides what is already stored; otherwise the default
+// of the flag is only stored when key is missing or an empty string.
+func (t *Target) StoreFlag(flagSet *flag.FlagSet, name, key string) {
+	f := flagSet.Lookup(name)
+	if f == nil {
+		return
+	}
+
+	if v, ok := t.Flags[key]; ok && v != "" && !isFlagSet(flagSet, name) {
+		return
+	}
+
+	if g, ok := f.Value.(flag.Getter); ok {
+		t.Flags[key] = g.Get()
+	} else {
+		t.Flags[key] = f.Value.String()
+	}
+}
+
+// FlagEnv returns the name of the environment variable setting the flag
+// name of the target. For example, flag `tag` of target `docker-buildx` is
+// set using `GOMAKE_DOCKER_BUILDX_TAG`.
+func (t *Target) FlagEnv(name string) string {
+	return FlagEnvPrefix + "_" + envName(t.Name) + "_" + envName(name)
+}
+
+// flagEnvNames returns the names of the environment variables setting the flag
+// name, the one taking precedence last.
+func (t *Target) flagEnvNames(name string) []string {
+	if v, ok := t.FlagEnvVars[name]; ok && v != "" {
+		return []string{v, t.FlagEnv(name)}
+	}
+	return []string{t.FlagEnv(name)}
+}
+
+// envName returns s in upper case with all characters not allowed in names
+// of environment variables replaced by an underscore.
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// extractProfile removes the -profile option from the flag arguments of
+// target, unless the target defines such flag itself or is a plugin, which
+// gets its arguments as they are. It returns the name of the profile and
+// whether it was found.
+func extractProfile(target *Target) (string, bool) {
+	if target.plugin != "" || target.knownFlagSet().Lookup("profile") != nil {
+		return "", false
+	}
+
+	var profile string
+	var found bool
+	var args []string
+
+	for i := 0; i < len(target.FlagArgs); i++ {
+		arg := target.FlagArgs[i]
+		if arg == "--" {
+			args = append(args, target.FlagArgs[i:]...)
+			break
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "profile" {
+			args = append(args, arg)
+			continue
+		}
+
+		found = true
+		if hasValue {
+			profile = value
+		} else if i+1 < len(target.FlagArgs) {
+			i++
+			profile = target.FlagArgs[i]
+		}
+	}
+
+	if found {
+		target.FlagArgs = args
+	}
+	return profile, found
+}
+
+// isFlagSet returns whether the flag name was given as argument.
+func isFlagSet(flagSet *flag.FlagSet, name string) bool {
+	found := false
+	flagSet.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// envFlagArgs returns the flags of target set through environment variables
+// as command line arguments. Flags which HandleFlags defines on a flag set of
+// its own are known only when HandleFlags succeeds without arguments.
+func (m *Maker) envFlagArgs(target *Target) ([]string, error) {
+	flagSet := target.knownFlagSet()
+	flagSet.SetOutput(io.Discard)
+
+	var args []string
+	var err error
+	flagSet.VisitAll(func(f *flag.Flag) {
+		for _, name := range target.flagEnvNames(f.Name) {
+			value, ok := m.lookupEnv(name)
+			if !ok || err != nil {
+				continue
+			}
+			if errSet := flagSet.Set(f.Name, value); errSet != nil {
+				err = fmt.Errorf("environment variable %s: invalid value %q: %w", name, value, errSet)
+				return
+			}
+			args = append(args, "-"+f.Name+"="+value)
+		}
+	})
+
+	return args, err
+}
+
+// configure applies flag values found in the configuration file, its selected
+// profile and environment variables, and the configured settings, to target. It returns
+// a function restoring the target, and the number of flag arguments, at the
+// start, which come from the configuration file and environment variables.
+func (m *Maker) configure(target *Target) (func(), int, error) {
+	var configArgs []string
+	settings := target.Settings
+
+	if m.config != nil {
+		// instances of pattern targets are configured like their pattern
+		names := []string{target.Name}
+		if target.pattern != "" {
+			names = []string{target.pattern, target.Name}
+		}
+
+		for _, tc := range m.config.targetConfigs(m.Profile, names...) {
+			args, err := m.config.flagArgs(target, tc)
+			if err != nil {
+				return nil, 0, err
+			}
+			configArgs = append(configArgs, args...)
+
+			if settings, err = m.config.settings(settings, tc); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+
+	envArgs, err := m.envFlagArgs(target)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	flagArgs, origSettings := target.FlagArgs, target.Settings
+
+	// arguments given later take precedence
+	args := append(configArgs, envArgs...)
+	target.FlagArgs = append(args, flagArgs...)
+	target.Settings = settings
+
+	return func() {
+		target.FlagArgs, target.Settings = flagArgs, origSettings
+	}, len(args), nil
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestTarget_FlagEnv(t *testing.T) {
+	target := &Target{Name: "docker-buildx"}
+	xt.Eq(t, "GOMAKE_DOCKER_BUILDX_TAG", target.FlagEnv("tag"))
+	xt.Eq(t, "GOMAKE_DOCKER_BUILDX_NO_CACHE", target.FlagEnv("no-cache"))
+	xt.Eq(t, "GOMAKE_DOCKER_BUILDX_F", target.FlagEnv("f"))
+}
+
+func TestFlagEnvOverrides(t *testing.T) {
+	newTarget := func(have *map[string]any) *Target {
+		return &Target{
+			Name:        "env-flags",
+			Description: "Target with flags",
+			DefineFlags: func(flagSet *flag.FlagSet) {
+				flagSet.String("tag", "latest", "Image tag")
+				flagSet.Bool("no-cache", false, "Do not use cache")
+			},
+			HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+				flagSet, err := target.ParseFlags()
+				if err != nil {
+					return nil, err
+				}
+				target.StoreFlag(flagSet, "tag", "tag")
+				target.StoreFlag(flagSet, "no-cache", "noCache")
+				return flagSet, nil
+			},
+			Do: func(target *Target) error {
+				*have = target.Flags
+				return nil
+			},
+		}
+	}
+
+	configFile := filepath.Join(t.TempDir(), "gomake.toml")
+	xt.OK(t, os.WriteFile(configFile, []byte("[targets.env-flags.flags]\ntag = \"config\"\n"), 0o600))
+
+	cases := []struct {
+		name   string
+		env    map[string]string
+		config bool
+		args   []string
+		exp    string
+	}{
+		{name: "default", exp: "latest"},
+		{name: "configuration file", config: true, exp: "config"},
+		{
+			name:   "environment beats configuration",
+			env:    map[string]string{"GOMAKE_ENV_FLAGS_TAG": "env"},
+			config: true,
+			exp:    "env",
+		},
+		{
+			name: "command line beats environment",
+			env:  map[string]string{"GOMAKE_ENV_FLAGS_TAG": "env"},
+			args: []string{"-tag", "cmdline"},
+			exp:  "cmdline",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for k, v := range c.env {
+				t.Setenv(k, v)
+			}
+
+			var have map[string]any
+			m := NewMaker()
+			m.StdOut = &strings.Builder{}
+			if c.config {
+				m.ConfigFile = configFile
+			}
+			m.registerTargets(newTarget(&have))
+
+			xt.Eq(t, 0, m.make(append([]string{"env-flags"}, c.args...)...))
+			xt.Eq(t, c.exp, have["tag"])
+		})
+	}
+
+	t.Run("boolean flag", func(t *testing.T) {
+		t.Setenv("GOMAKE_ENV_FLAGS_NO_CACHE", "true")
+
+		var have map[string]any
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.registerTargets(newTarget(&have))
+
+		xt.Eq(t, 0, m.make("env-flags"))
+		xt.Eq(t, true, have["noCache"])
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		t.Setenv("GOMAKE_ENV_FLAGS_NO_CACHE", "maybe")
+
+		var bufErr strings.Builder
+		var have map[string]any
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.StdErr = &bufErr
+		m.registerTargets(newTarget(&have))
+
+		xt.Eq(t, 1, m.make("env-flags"))
+		xt.Assert(t, strings.Contains(bufErr.String(),
+			`environment variable GOMAKE_ENV_FLAGS_NO_CACHE: invalid value "maybe"`), bufErr.String())
+	})
+
+	t.Run("flags defined only by HandleFlags", func(t *testing.T) {
+		t.Setenv("GOMAKE_LEGACY_TAG", "env")
+
+		var have string
+		newMaker := func() (*Maker, *strings.Builder) {
+			var buf strings.Builder
+			m := NewMaker()
+			m.StdOut = &buf
+			m.registerTargets(&Target{
+				Name: "legacy",
+				HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+					flagSet := flag.NewFlagSet(target.Name, flag.ContinueOnError)
+					flagSet.StringVar(&have, "tag", "latest", "Image tag")
+					return flagSet, flagSet.Parse(target.FlagArgs)
+				},
+				Do: func(target *Target) error { return nil },
+			})
+			return m, &buf
+		}
+
+		m, _ := newMaker()
+		xt.Eq(t, 0, m.make("legacy"))
+		xt.Eq(t, "env", have)
+
+		m, _ = newMaker()
+		xt.Eq(t, 0, m.make("legacy", "-tag", "cmdline"))
+		xt.Eq(t, "cmdline", have)
+
+		m, buf := newMaker()
+		xt.Eq(t, 0, m.make("help", "legacy"))
+		xt.Eq(t, `Usage: legacy [flags]
+
+Flags:
+   -tag string
+      Image tag (default: "latest")
+      environment: GOMAKE_LEGACY_TAG
+`, buf.String())
+	})
+
+	t.Run("help shows environment variables", func(t *testing.T) {
+		exp := `Usage: env-flags [flags]
+   Target with flags
+
+Flags:
+   -no-cache
+      Do not use cache
+      environment: GOMAKE_ENV_FLAGS_NO_CACHE
+   -tag string
+      Image tag (default: "latest")
+      environment: GOMAKE_ENV_FLAGS_TAG
+`
+		var buf strings.Builder
+		var have map[string]any
+		m := NewMaker()
+		m.StdOut = &buf
+		m.registerTargets(newTarget(&have))
+
+		xt.Eq(t, 0, m.make("help", "env-flags"))
+		xt.Eq(t, exp, buf.String())
+	})
+}
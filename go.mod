@@ -3,7 +3,10 @@ module github.com/golistic/gomake
 go 1.20
 
 require (
-	github.com/golistic/shieldbadger v0.0.0-20230223210348-5649a4ba6aa9 // indirect
-	github.com/golistic/xt v1.0.1 // indirect
-	golang.org/x/mod v0.8.0 // indirect
+	github.com/BurntSushi/toml v1.6.0
+	github.com/golistic/shieldbadger v0.0.0-20230223210348-5649a4ba6aa9
+	github.com/golistic/xt v1.0.1
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/mod v0.8.0 // indirect
@@ -13,7 +13,17 @@ import (
 var defaultMake = NewMaker()
 
 func Make() {
+	defaultMake.setFlags(flag.CommandLine)
 	flag.Parse()
+
+	if defaultMake.ConfigFile == "" {
+		var err error
+		if defaultMake.ConfigFile, err = FindConfigFile("."); err != nil {
+			defaultMake.Log.Error(err)
+			os.Exit(1)
+		}
+	}
+
 	os.Exit(defaultMake.make(flag.Args()...))
 }
 
@@ -21,7 +31,19 @@ func RegisterTargets(targets ...*Target) {
 	defaultMake.registerTargets(targets...)
 }
 
-func execCmd(stdOut io.Writer, stdErr io.Writer, env []string, cmdAndArgs ...string) error {
+// Default returns the Maker used by Make and RegisterTargets.
+func Default() *Maker {
+	return defaultMake
+}
+
+// SetLogPrefix sets the prefix shown in front of messages of the default Maker.
+func SetLogPrefix(prefix string) {
+	defaultMake.Log.Prefix = prefix
+}
+
+// execCmd runs cmdAndArgs for target. Variables in env are added to the
+// environment of the target.
+func execCmd(target *Target, stdOut io.Writer, stdErr io.Writer, env []string, cmdAndArgs ...string) error {
 	if len(cmdAndArgs) == 0 {
 		return fmt.Errorf("no command provided")
 	}
@@ -43,13 +65,5 @@ func execCmd(stdOut io.Writer, stdErr io.Writer, env []string, cmdAndArgs ...str
 
 	cmd.Env = env
 
-	if err := cmd.Start(); err != nil {
-		return err
-	}
-
-	if err := cmd.Wait(); err != nil {
-		return err
-	}
-
-	return nil
+	return target.RunCmd(cmd)
 }
@@ -0,0 +1,185 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Kinds of edges of the target graph.
+const (
+	edgePrerequisite = "prerequisite"
+	edgeDeferred     = "deferred"
+	edgeConditional  = "conditional"
+)
+
+// graphEdge is an edge of the target graph, from a target to a target it
+// executes.
+type graphEdge struct {
+	from, to *Target
+	kind     string
+}
+
+// builtinGraph shows the graph of all registered targets, or the subgraph of
+// the given target, as Graphviz DOT or as Mermaid flowchart.
+func (m *Maker) builtinGraph(args []string) int {
+	flagSet := flag.NewFlagSet("graph", flag.ContinueOnError)
+	flagSet.SetOutput(m.StdErr)
+	format := flagSet.String("format", "dot", "Output format: dot or mermaid")
+
+	// flags can be given before and after the name of the target
+	var names []string
+	for {
+		if err := flagSet.Parse(args); err != nil {
+			return 1
+		}
+		args = flagSet.Args()
+		if len(args) == 0 {
+			break
+		}
+		names, args = append(names, args[0]), args[1:]
+	}
+
+	var render func(nodes []*Target, edges []graphEdge) string
+	switch *format {
+	case "dot":
+		render = renderDOT
+	case "mermaid":
+		render = renderMermaid
+	default:
+		m.Log.Errorf("unknown graph format %s; use dot or mermaid", *format)
+		return 1
+	}
+
+	var roots []*Target
+	if len(names) > 0 {
+		name := strings.Join(names, NamespaceSeparator)
+		target, ok := m.lookupTarget(name)
+		if !ok {
+			m.Log.Errorf("target %s not available\n\n%s", name, helpAvailableTargets(m))
+			return 1
+		}
+		roots = []*Target{target}
+	} else {
+		for _, name := range sortedKeys(m.targetRegistry) {
+			roots = append(roots, m.targetRegistry[name])
+		}
+	}
+
+	_, _ = fmt.Fprint(m.StdOut, render(targetGraph(roots)))
+	return 0
+}
+
+// targetGraph returns roots and the targets they execute, directly or
+// indirectly, in the order they are found, together with the edges between
+// them. Targets are identified by name.
+func targetGraph(roots []*Target) ([]*Target, []graphEdge) {
+	var nodes []*Target
+	var edges []graphEdge
+	seen := map[string]bool{}
+
+	var walk func(t *Target)
+	walk = func(t *Target) {
+		if seen[t.Name] {
+			return
+		}
+		seen[t.Name] = true
+		nodes = append(nodes, t)
+
+		for _, e := range targetEdges(t) {
+			edges = append(edges, e)
+			walk(e.to)
+		}
+	}
+
+	for _, t := range roots {
+		walk(t)
+	}
+
+	return nodes, edges
+}
+
+// targetEdges returns the edges from t to the targets it executes. The
+// targets combined by Finally, except the first, are deferred; the target
+// of OnlyIf is conditional.
+func targetEdges(t *Target) []graphEdge {
+	var edges []graphEdge
+	for _, pre := range t.PreTargets {
+		edges = append(edges, graphEdge{from: t, to: pre, kind: edgePrerequisite})
+	}
+
+	for i, child := range t.children {
+		kind := edgePrerequisite
+		switch {
+		case t.combinator == "only-if":
+			kind = edgeConditional
+		case t.combinator == "finally" && i > 0:
+			kind = edgeDeferred
+		}
+		edges = append(edges, graphEdge{from: t, to: child, kind: kind})
+	}
+
+	for _, deferred := range t.DeferredTargets {
+		edges = append(edges, graphEdge{from: t, to: deferred, kind: edgeDeferred})
+	}
+
+	return edges
+}
+
+// renderDOT returns the graph using the DOT language of Graphviz.
+// Deferred edges are dashed, and conditional edges bold and labeled.
+func renderDOT(nodes []*Target, edges []graphEdge) string {
+	var sb strings.Builder
+	sb.WriteString("digraph targets {\n\trankdir=LR;\n\tnode [shape=box];\n\n")
+
+	for _, t := range nodes {
+		sb.WriteString("\t" + strconv.Quote(t.Name) + ";\n")
+	}
+
+	if len(edges) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, e := range edges {
+		sb.WriteString("\t" + strconv.Quote(e.from.Name) + " -> " + strconv.Quote(e.to.Name))
+		switch e.kind {
+		case edgeDeferred:
+			sb.WriteString(` [style=dashed, label="deferred"]`)
+		case edgeConditional:
+			sb.WriteString(` [style=bold, label="if"]`)
+		}
+		sb.WriteString(";\n")
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// renderMermaid returns the graph as Mermaid flowchart. Deferred edges are
+// dotted, and conditional edges thick, both labeled.
+func renderMermaid(nodes []*Target, edges []graphEdge) string {
+	ids := map[string]string{}
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+
+	for i, t := range nodes {
+		ids[t.Name] = "t" + strconv.Itoa(i+1)
+		label := strings.ReplaceAll(t.Name, `"`, "#quot;")
+		sb.WriteString("\t" + ids[t.Name] + `["` + label + "\"]\n")
+	}
+
+	for _, e := range edges {
+		arrow := " --> "
+		switch e.kind {
+		case edgeDeferred:
+			arrow = " -.->|deferred| "
+		case edgeConditional:
+			arrow = " ==>|if| "
+		}
+		sb.WriteString("\t" + ids[e.from.Name] + arrow + ids[e.to.Name] + "\n")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestMaker_graph(t *testing.T) {
+	newMaker := func() (*Maker, *strings.Builder, *strings.Builder) {
+		tools := NewTarget("tools", nil)
+		cleanup := NewTarget("cleanup", nil)
+		build := NewTarget("build", nil, WithPreTargets(tools), WithDeferredTargets(cleanup))
+		push := OnlyIf(func(*Target) bool { return true }, NewTarget("push", nil))
+		release := Finally(Seq(build, push), NewTarget(`remove "tmp"`, nil))
+		release.Name = "release"
+
+		m := NewMaker()
+		stdOut, stdErr := &strings.Builder{}, &strings.Builder{}
+		m.StdOut, m.StdErr = stdOut, stdErr
+		m.LogDir = ""
+		m.registerTargets(build, release, NewTarget("lint", nil))
+		return m, stdOut, stdErr
+	}
+
+	t.Run("DOT of all targets", func(t *testing.T) {
+		m, stdOut, _ := newMaker()
+		xt.Eq(t, 0, m.make("graph"))
+		xt.Eq(t, `digraph targets {
+	rankdir=LR;
+	node [shape=box];
+
+	"build";
+	"tools";
+	"cleanup";
+	"lint";
+	"release";
+	"seq(build,only-if(push))";
+	"only-if(push)";
+	"push";
+	"remove \"tmp\"";
+
+	"build" -> "tools";
+	"build" -> "cleanup" [style=dashed, label="deferred"];
+	"release" -> "seq(build,only-if(push))";
+	"seq(build,only-if(push))" -> "build";
+	"seq(build,only-if(push))" -> "only-if(push)";
+	"only-if(push)" -> "push" [style=bold, label="if"];
+	"release" -> "remove \"tmp\"" [style=dashed, label="deferred"];
+}
+`, stdOut.String())
+	})
+
+	t.Run("Mermaid of one target", func(t *testing.T) {
+		m, stdOut, _ := newMaker()
+		xt.Eq(t, 0, m.make("graph", "build", "-format", "mermaid"))
+		xt.Eq(t, `flowchart LR
+	t1["build"]
+	t2["tools"]
+	t3["cleanup"]
+	t1 --> t2
+	t1 -.->|deferred| t3
+`, stdOut.String())
+	})
+
+	t.Run("Mermaid escapes labels", func(t *testing.T) {
+		m, stdOut, _ := newMaker()
+		xt.Eq(t, 0, m.make("graph", "-format", "mermaid", "release"))
+		out := stdOut.String()
+		xt.Assert(t, strings.Contains(out, "\tt8[\"remove #quot;tmp#quot;\"]\n"), out)
+		xt.Assert(t, strings.Contains(out, "\tt6 ==>|if| t7\n"), out)
+	})
+
+	t.Run("help shows built-ins shadowed by targets", func(t *testing.T) {
+		m, stdOut, _ := newMaker()
+		m.registerTargets(NewTarget("graph", nil))
+		xt.Eq(t, 0, m.make("help"))
+		out := stdOut.String()
+		xt.Assert(t, strings.Contains(out, "\nBuilt-in commands:\n   graph [-format dot|mermaid] [target]\n"+
+			"      Not available: target graph is registered\n"), out)
+		xt.Assert(t, strings.Contains(out, "   logs [target ...]\n      Shows the logs of the last run\n"), out)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		m, _, stdErr := newMaker()
+		xt.Eq(t, 1, m.make("graph", "-format", "svg"))
+		xt.Assert(t, strings.Contains(stdErr.String(), "unknown graph format svg; use dot or mermaid"), stdErr.String())
+
+		xt.Eq(t, 1, m.make("graph", "deploy"))
+		xt.Assert(t, strings.Contains(stdErr.String(), "target deploy not available"), stdErr.String())
+	})
+}
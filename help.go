@@ -2,23 +2,127 @@
 
 package gomake
 
-import "sort"
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// builtinCommand describes a command of the Maker which is not a target.
+type builtinCommand struct {
+	name        string
+	usage       string
+	description string
+}
+
+// builtinCommands are the commands shown in help. Registered targets take
+// precedence over all of them, except help.
+var builtinCommands = []builtinCommand{
+	{name: "graph", usage: "graph [-format dot|mermaid] [target]",
+		description: "Shows the dependency graph of all targets, or of target"},
+	{name: "help", usage: "help [target|namespace]",
+		description: "Shows the available targets, or the usage of target"},
+	{name: "logs", usage: "logs [target ...]",
+		description: "Shows the logs of the last run"},
+	{name: "shims", usage: "shims [-check] [-just] [-dir DIR] [-cmd COMMAND] [-force]",
+		description: "Writes a Makefile, and optionally a justfile, forwarding to the targets"},
+}
 
 func helpAvailableTargets(m *Maker) string {
-	help := "Available targets:\n"
+	return "Available targets:\n" + helpTargets(m, "") + "\nBuilt-in commands:\n" + helpBuiltins(m)
+}
+
+// helpBuiltins lists the built-in commands, noting those which are not
+// available because a target with the same name is registered.
+func helpBuiltins(m *Maker) string {
+	var sb strings.Builder
+	for _, b := range builtinCommands {
+		sb.WriteString("   " + b.usage + "\n")
+		if _, ok := m.targetRegistry[b.name]; ok && b.name != "help" {
+			sb.WriteString("      Not available: target " + b.name + " is registered\n")
+			continue
+		}
+		sb.WriteString("      " + b.description + "\n")
+	}
+	return sb.String()
+}
+
+// helpNamespace returns the targets available within namespace.
+func helpNamespace(m *Maker, namespace string) string {
+	return "Available targets in " + namespace + ":\n" + helpTargets(m, namespace)
+}
 
-	var names []string
-	for _, target := range m.targetRegistry {
-		names = append(names, target.Name)
+// helpTargets lists the targets within namespace, which is empty for all
+// targets. Targets which are not mounted come first, followed by those of
+// each namespace under a heading.
+func helpTargets(m *Maker, namespace string) string {
+	byNamespace := map[string][]string{}
+	for name := range m.targetRegistry {
+		ns := namespaceOf(name)
+		if namespace == "" || ns == namespace || strings.HasPrefix(ns, namespace+NamespaceSeparator) {
+			byNamespace[ns] = append(byNamespace[ns], name)
+		}
 	}
 
-	sort.Strings(names)
-	for _, name := range names {
-		target := m.targetRegistry[name]
-		help += "   " + name + "\n"
-		if target.Description != "" {
-			help += "      " + target.Description + "\n"
+	var sb strings.Builder
+	for i, ns := range sortedKeys(byNamespace) {
+		if ns != namespace {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(ns + NamespaceSeparator + "\n")
+		}
+
+		names := byNamespace[ns]
+		sort.Strings(names)
+		for _, name := range names {
+			target := m.targetRegistry[name]
+			sb.WriteString("   " + name + "\n")
+			if target.Description != "" {
+				sb.WriteString("      " + target.Description + "\n")
+			}
 		}
 	}
-	return help
+	return sb.String()
+}
+
+// helpTarget returns the usage of target, showing its flags together with
+// the environment variables which can be used to set them.
+func helpTarget(target *Target) string {
+	var sb strings.Builder
+
+	_, _ = fmt.Fprintf(&sb, "Usage: %s [flags]\n", target.Name)
+	if target.Description != "" {
+		_, _ = fmt.Fprintf(&sb, "   %s\n", target.Description)
+	}
+
+	var flags []*flag.Flag
+	target.knownFlagSet().VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+
+	if len(flags) == 0 {
+		return sb.String()
+	}
+
+	sb.WriteString("\nFlags:\n")
+	for _, f := range flags {
+		typeName, usage := flag.UnquoteUsage(f)
+		sb.WriteString("   -" + f.Name)
+		if typeName != "" {
+			sb.WriteString(" " + typeName)
+		}
+		switch f.DefValue {
+		case "", "false", "0", "0s":
+		default:
+			usage = strings.TrimSpace(fmt.Sprintf("%s (default: %q)", usage, f.DefValue))
+		}
+		if usage != "" {
+			sb.WriteString("\n      " + usage)
+		}
+		_, _ = fmt.Fprintf(&sb, "\n      environment: %s\n", strings.Join(target.flagEnvNames(f.Name), ", "))
+	}
+
+	return sb.String()
 }
@@ -0,0 +1,386 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+)
+
+// TemplateData is the data available to templates in flag values and
+// settings of targets. For example, `{{ .Git.Describe }}` or
+// `{{ .Env.APP_NAME }}`.
+type TemplateData struct {
+	// Target is the target being executed.
+	Target *Target
+	// Git provides information about the Git repository in which the
+	// target is executed.
+	Git *GitInfo
+	// Env holds the environment of the target.
+	Env map[string]string
+	// Go holds the Go environment as reported by `go env`.
+	Go map[string]string
+	// Targets holds the reports of the targets executed so far during the
+	// run by their name.
+	Targets map[string]*TargetReport
+	// Outputs holds the values published by targets executed so far during
+	// the run, by target name and output name.
+	Outputs map[string]map[string]any
+}
+
+// GitInfo provides information about a Git repository. Each value is
+// retrieved when first used.
+type GitInfo struct {
+	dir    string
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func newGitInfo(dir string) *GitInfo {
+	return &GitInfo{dir: dir, values: map[string]string{}}
+}
+
+// Commit returns the hash of the commit checked out.
+func (g *GitInfo) Commit() (string, error) {
+	return g.git("rev-parse", "HEAD")
+}
+
+// ShortCommit returns the abbreviated hash of the commit checked out.
+func (g *GitInfo) ShortCommit() (string, error) {
+	return g.git("rev-parse", "--short", "HEAD")
+}
+
+// Branch returns the name of the branch checked out.
+func (g *GitInfo) Branch() (string, error) {
+	return g.git("rev-parse", "--abbrev-ref", "HEAD")
+}
+
+// Tag returns the tag of the commit checked out, or an empty string when
+// the commit is not tagged.
+func (g *GitInfo) Tag() (string, error) {
+	tag, err := g.git("tag", "--points-at", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	tag, _, _ = strings.Cut(tag, "\n")
+	return tag, nil
+}
+
+// Describe returns the most recent tag with the number of commits since,
+// and whether the work tree has modifications, as `git describe` does.
+func (g *GitInfo) Describe() (string, error) {
+	return g.git("describe", "--tags", "--always", "--dirty")
+}
+
+// Dirty returns whether the work tree has uncommitted modifications.
+func (g *GitInfo) Dirty() (bool, error) {
+	status, err := g.git("status", "--porcelain")
+	return status != "", err
+}
+
+func (g *GitInfo) git(args ...string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := strings.Join(args, " ")
+	if v, ok := g.values[key]; ok {
+		return v, nil
+	}
+
+	var stdErr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.dir
+	cmd.Stderr = &stdErr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stdErr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", key, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", key, err)
+	}
+
+	v := strings.TrimSpace(string(out))
+	g.values[key] = v
+	return v, nil
+}
+
+// goEnv returns the Go environment, retrieving it once per run.
+func (m *Maker) goEnv() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.goEnvVars != nil {
+		return m.goEnvVars
+	}
+
+	m.goEnvVars = map[string]string{}
+	if out, err := exec.Command("go", "env", "-json").Output(); err == nil {
+		_ = json.Unmarshal(out, &m.goEnvVars)
+	}
+	return m.goEnvVars
+}
+
+// templateData returns the data used to expand templates of target.
+func (m *Maker) templateData(target *Target) *TemplateData {
+	data := &TemplateData{
+		Target:  target,
+		Git:     newGitInfo(target.WorkDir),
+		Env:     map[string]string{},
+		Go:      m.goEnv(),
+		Targets: map[string]*TargetReport{},
+		Outputs: m.outputs(),
+	}
+
+	for _, kv := range target.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		data.Env[k] = v
+	}
+
+	m.mu.Lock()
+	if m.report != nil {
+		for _, tr := range m.report.Targets {
+			data.Targets[tr.Name] = tr
+		}
+	}
+	m.mu.Unlock()
+
+	return data
+}
+
+// Expand executes text as template using the data described by TemplateData.
+// Referring to variables which do not exist is an error.
+func (t *Target) Expand(text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	m := t.Maker
+	if m == nil {
+		m = defaultMake
+	}
+
+	return expandTemplate(text, m.templateData(t))
+}
+
+func expandTemplate(text string, data *TemplateData) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// refersToOutputs returns whether the template text refers to the outputs
+// of targets, as field `.Outputs` or variable `$.Outputs`, for example in
+// `{{ .Outputs.version.tag }}` or `{{ index .Outputs "go-version" "version" }}`.
+// Text which is not a valid template does not refer to outputs.
+func refersToOutputs(text string) bool {
+	tmpl, err := template.New("").Parse(text)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil && nodeRefersToOutputs(t.Tree.Root) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeRefersToOutputs(node parse.Node) bool {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		return len(n.Ident) > 0 && n.Ident[0] == "Outputs"
+	case *parse.VariableNode:
+		return len(n.Ident) > 1 && n.Ident[0] == "$" && n.Ident[1] == "Outputs"
+	case *parse.ChainNode:
+		return nodeRefersToOutputs(n.Node)
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, e := range n.Nodes {
+			if nodeRefersToOutputs(e) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return nodeRefersToOutputs(n.Pipe)
+	case *parse.TemplateNode:
+		return nodeRefersToOutputs(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if nodeRefersToOutputs(cmd) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if nodeRefersToOutputs(arg) {
+				return true
+			}
+		}
+	case *parse.IfNode:
+		return nodeRefersToOutputs(&n.BranchNode)
+	case *parse.RangeNode:
+		return nodeRefersToOutputs(&n.BranchNode)
+	case *parse.WithNode:
+		return nodeRefersToOutputs(&n.BranchNode)
+	case *parse.BranchNode:
+		return nodeRefersToOutputs(n.Pipe) || nodeRefersToOutputs(n.List) || nodeRefersToOutputs(n.ElseList)
+	}
+	return false
+}
+
+// expander expands templates found in strings of flags and settings.
+type expander struct {
+	target *Target
+	data   *TemplateData
+	// skipOutputs, when true, leaves templates referring to outputs as they
+	// are, and records in skipped that it did.
+	skipOutputs bool
+	skipped     bool
+}
+
+func (x *expander) string(key, s string) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	if x.skipOutputs && refersToOutputs(s) {
+		x.skipped = true
+		return s, nil
+	}
+
+	if x.data == nil {
+		x.data = x.target.Maker.templateData(x.target)
+	}
+
+	res, err := expandTemplate(s, x.data)
+	if err != nil {
+		return "", fmt.Errorf("%s: expanding %s: %w", x.target.Name, key, err)
+	}
+	return res, nil
+}
+
+// value returns a copy of v with templates expanded in strings, including
+// those in slices and maps.
+func (x *expander) value(key string, v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return x.string(key, v)
+	case []string:
+		res := make([]string, len(v))
+		for i, s := range v {
+			var err error
+			if res[i], err = x.string(fmt.Sprintf("%s[%d]", key, i), s); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case [][]string:
+		res := make([][]string, len(v))
+		for i, s := range v {
+			e, err := x.value(fmt.Sprintf("%s[%d]", key, i), s)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = e.([]string)
+		}
+		return res, nil
+	case []any:
+		res := make([]any, len(v))
+		for i, e := range v {
+			var err error
+			if res[i], err = x.value(fmt.Sprintf("%s[%d]", key, i), e); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case map[string]string:
+		res := make(map[string]string, len(v))
+		for k, s := range v {
+			var err error
+			if res[k], err = x.string(key+"."+k, s); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case map[string]any:
+		return x.mapValue(key, v)
+	default:
+		return v, nil
+	}
+}
+
+func (x *expander) mapValue(key string, m map[string]any) (map[string]any, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	res := make(map[string]any, len(m))
+	for _, k := range sortedKeys(m) {
+		subKey := k
+		if key != "" {
+			subKey = key + "." + k
+		}
+		v, err := x.value(subKey, m[k])
+		if err != nil {
+			return nil, err
+		}
+		res[k] = v
+	}
+	return res, nil
+}
+
+// expand expands templates in the flags, the configured flag arguments and
+// the settings of target; configured is the number of flag arguments, at the
+// start, which were configured. Arguments given on the command line are
+// never expanded. When skipOutputs is true, templates referring to outputs
+// are left as they are, and skipped reports whether there were any. It
+// returns a function restoring the target.
+func (m *Maker) expand(target *Target, configured int, skipOutputs bool) (restore func(), skipped bool, err error) {
+	x := &expander{target: target, skipOutputs: skipOutputs}
+
+	flags, err := x.mapValue("flags", target.Flags)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var flagArgs []string
+	if target.FlagArgs != nil {
+		flagArgs = make([]string, len(target.FlagArgs))
+		copy(flagArgs, target.FlagArgs)
+		for i, arg := range flagArgs[:configured] {
+			if flagArgs[i], err = x.string("argument "+arg, arg); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	settings, err := x.mapValue("settings", target.Settings)
+	if err != nil {
+		return nil, false, err
+	}
+
+	origFlags, origFlagArgs, origSettings := target.Flags, target.FlagArgs, target.Settings
+	target.Flags, target.FlagArgs, target.Settings = flags, flagArgs, settings
+
+	return func() {
+		target.Flags, target.FlagArgs, target.Settings = origFlags, origFlagArgs, origSettings
+	}, x.skipped, nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestInterpolation(t *testing.T) {
+	newTarget := func(have **Target) *Target {
+		return &Target{
+			Name: "interpolated",
+			DefineFlags: func(flagSet *flag.FlagSet) {
+				flagSet.String("image", "", "Docker image")
+				flagSet.String("tag", "", "Docker image tag")
+			},
+			HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+				flagSet, err := target.ParseFlags()
+				if err != nil {
+					return nil, err
+				}
+				target.StoreFlag(flagSet, "image", "image")
+				target.StoreFlag(flagSet, "tag", "tag")
+				return flagSet, nil
+			},
+			Do: func(target *Target) error {
+				cp := *target
+				*have = &cp
+				return nil
+			},
+		}
+	}
+
+	t.Run("flags, arguments and settings", func(t *testing.T) {
+		t.Setenv("APP_NAME", "myapp")
+
+		var have *Target
+		target := newTarget(&have)
+		target.Env = Env{Set: map[string]string{"VERSION": "1.2.3"}}
+		target.Flags = map[string]any{"image": "{{ .Env.APP_NAME }}"}
+		target.Settings = map[string]any{
+			"integration": [][]string{{"go", "run", "./cmd/{{ .Env.APP_NAME }}", "-os={{ .Go.GOOS }}"}},
+			"nested":      map[string]any{"name": "{{ .Target.Name }}"},
+			"number":      42,
+		}
+
+		configFile := filepath.Join(t.TempDir(), "gomake.json")
+		xt.OK(t, os.WriteFile(configFile,
+			[]byte(`{"targets": {"interpolated": {"flags": {"tag": "v{{ .Env.VERSION }}"}}}}`), 0o600))
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.ConfigFile = configFile
+		m.registerTargets(target)
+
+		xt.Eq(t, 0, m.make("interpolated"))
+		xt.Eq(t, "myapp", have.Flags["image"])
+		xt.Eq(t, "v1.2.3", have.Flags["tag"])
+		xt.Eq(t, [][]string{{"go", "run", "./cmd/myapp", "-os=" + runtime.GOOS}}, have.Settings["integration"])
+		xt.Eq(t, map[string]any{"name": "interpolated"}, have.Settings["nested"])
+		xt.Eq(t, 42, have.Settings["number"])
+
+		// target itself is left untouched
+		xt.Eq(t, "{{ .Env.APP_NAME }}", target.Flags["image"])
+		xt.Eq(t, "./cmd/{{ .Env.APP_NAME }}", target.Settings["integration"].([][]string)[0][2])
+	})
+
+	t.Run("command line arguments are not expanded", func(t *testing.T) {
+		var have *Target
+		target := newTarget(&have)
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.registerTargets(target)
+
+		xt.Eq(t, 0, m.make("interpolated", "-tag", "{{ .Env.VERSION"))
+		xt.Eq(t, "{{ .Env.VERSION", have.Flags["tag"])
+	})
+
+	t.Run("unknown variables", func(t *testing.T) {
+		cases := map[string]string{
+			"{{ .Env.GOMAKE_NOT_SET }}": `map has no entry for key "GOMAKE_NOT_SET"`,
+			"{{ .Version }}":            `can't evaluate field Version`,
+			"{{ .Env.APP_NAME":          `unclosed action`,
+		}
+
+		for text, exp := range cases {
+			var have *Target
+			target := newTarget(&have)
+			target.Flags = map[string]any{"image": text}
+
+			var bufErr strings.Builder
+			m := NewMaker()
+			m.StdOut = &strings.Builder{}
+			m.StdErr = &bufErr
+			m.registerTargets(target)
+
+			xt.Eq(t, 1, m.make("interpolated"))
+			xt.Assert(t, strings.Contains(bufErr.String(), "interpolated: expanding flags.image: "), bufErr.String())
+			xt.Assert(t, strings.Contains(bufErr.String(), exp), bufErr.String())
+		}
+	})
+
+	t.Run("git", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("the 'git' command is not available in PATH")
+		}
+
+		dir := t.TempDir()
+		for _, args := range [][]string{
+			{"init", "-q"},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com",
+				"commit", "-q", "--allow-empty", "-m", "initial"},
+			{"tag", "v0.1.0"},
+		} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = dir
+			out, err := cmd.CombinedOutput()
+			xt.OK(t, err, string(out))
+		}
+
+		target := &Target{Name: "git", WorkDir: dir, Maker: NewMaker()}
+
+		have, err := target.Expand("{{ .Git.Describe }} {{ .Git.Tag }} {{ .Git.Dirty }}")
+		xt.OK(t, err)
+		xt.Eq(t, "v0.1.0 v0.1.0 false", have)
+
+		commit, err := target.Expand("{{ .Git.Commit }}")
+		xt.OK(t, err)
+		xt.Eq(t, 40, len(commit))
+	})
+}
+
+func TestRefersToOutputs(t *testing.T) {
+	cases := map[string]bool{
+		`{{ .Outputs.version.tag }}`:                                                 true,
+		`{{ index .Outputs "go-version" "version" }}`:                                true,
+		`{{ $.Outputs.version.tag }}`:                                                true,
+		`{{ (index .Outputs "go-version").version }}`:                                true,
+		`{{ if .Outputs.version }}v{{ end }}`:                                        true,
+		`{{ with .Env.TAG }}{{ . }}{{ else }}{{ index $.Outputs "v" "t" }}{{ end }}`: true,
+		`release.Outputs {{ .Env.TAG }}`:                                             false,
+		`{{ .Env.Outputs }}`:                                                         false,
+		`{{ index .Env "Outputs" }}`:                                                 false,
+		`{{ .Outputs`:                                                                false,
+	}
+
+	for text, exp := range cases {
+		xt.Eq(t, exp, refersToOutputs(text), text)
+	}
+}
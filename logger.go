@@ -0,0 +1,175 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Level is the severity of a log message.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// ColorMode defines when log messages are colored.
+type ColorMode int
+
+const (
+	// ColorAuto colors messages when they are written to a terminal and the
+	// NO_COLOR environment variable is not set, or empty.
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+const (
+	ansiReset  = "\033[0m"
+	ansiDim    = "\033[2m"
+	ansiBlue   = "\033[1;34m"
+	ansiYellow = "\033[1;33m"
+	ansiRed    = "\033[1;31m"
+)
+
+// Logger writes leveled messages of a Maker. Debug and info messages are
+// written to the standard output of the Maker, prefixed with Prefix. Warnings
+// and errors are written to the standard error of the Maker.
+type Logger struct {
+	// Level is the minimum level of messages being written.
+	Level Level
+	// Prefix is shown in front of debug and info messages.
+	Prefix string
+	Color  ColorMode
+
+	maker *Maker
+}
+
+func newLogger(m *Maker) *Logger {
+	return &Logger{
+		Level:  LevelInfo,
+		Prefix: "==>",
+		maker:  m,
+	}
+}
+
+func (l *Logger) Debug(a ...any) {
+	l.log(LevelDebug, sprintln(a...))
+}
+
+func (l *Logger) Debugf(format string, a ...any) {
+	l.log(LevelDebug, fmt.Sprintf(format, a...))
+}
+
+func (l *Logger) Info(a ...any) {
+	l.log(LevelInfo, sprintln(a...))
+}
+
+func (l *Logger) Infof(format string, a ...any) {
+	l.log(LevelInfo, fmt.Sprintf(format, a...))
+}
+
+func (l *Logger) Warn(a ...any) {
+	l.log(LevelWarn, sprintln(a...))
+}
+
+func (l *Logger) Warnf(format string, a ...any) {
+	l.log(LevelWarn, fmt.Sprintf(format, a...))
+}
+
+func (l *Logger) Error(a ...any) {
+	l.log(LevelError, sprintln(a...))
+}
+
+func (l *Logger) Errorf(format string, a ...any) {
+	l.log(LevelError, fmt.Sprintf(format, a...))
+}
+
+func (l *Logger) log(level Level, msg string) {
+	if level < l.Level {
+		return
+	}
+
+	var w io.Writer
+	var label, color string
+
+	switch level {
+	case LevelDebug:
+		w, label, color = l.maker.StdOut, l.Prefix, ansiDim
+	case LevelInfo:
+		w, label, color = l.maker.StdOut, l.Prefix, ansiBlue
+	case LevelWarn:
+		w, label, color = l.maker.StdErr, "Warning:", ansiYellow
+	default:
+		w, label, color = l.maker.StdErr, "Error:", ansiRed
+	}
+
+	if l.useColor(w) {
+		label = color + label + ansiReset
+	}
+
+	if label != "" {
+		msg = label + " " + msg
+	}
+
+	_, _ = fmt.Fprintln(w, strings.TrimRight(msg, "\n"))
+}
+
+func (l *Logger) useColor(w io.Writer) bool {
+	switch l.Color {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	case ColorAuto:
+	}
+
+	// see https://no-color.org: only a value which is not empty counts
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	return isTerminal(w)
+}
+
+// wrappedWriter is implemented by writers of the Maker which pass output on
+// to another writer.
+type wrappedWriter interface {
+	unwrap() io.Writer
+}
+
+// isTerminal returns whether w, or the writer it wraps, is a file referring to
+// a terminal.
+func isTerminal(w io.Writer) bool {
+	for {
+		ww, ok := w.(wrappedWriter)
+		if !ok {
+			break
+		}
+		w = ww.unwrap()
+	}
+
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// sprintln formats like fmt.Sprintln, always adding spaces between operands,
+// but without the new line.
+func sprintln(a ...any) string {
+	return strings.TrimSuffix(fmt.Sprintln(a...), "\n")
+}
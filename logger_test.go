@@ -0,0 +1,103 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestLogger(t *testing.T) {
+	newTestMaker := func() (*Maker, *strings.Builder, *strings.Builder) {
+		var bufOut, bufErr strings.Builder
+		m := NewMaker()
+		m.StdOut = &bufOut
+		m.StdErr = &bufErr
+		return m, &bufOut, &bufErr
+	}
+
+	t.Run("levels", func(t *testing.T) {
+		m, bufOut, bufErr := newTestMaker()
+
+		m.Log.Debug("not shown")
+		m.Log.Info("info", 1)
+		m.Log.Warnf("warning %d", 2)
+		m.Log.Errorf("error %d\n", 3)
+
+		xt.Eq(t, "==> info 1\n", bufOut.String())
+		xt.Eq(t, "Warning: warning 2\nError: error 3\n", bufErr.String())
+
+		bufOut.Reset()
+		m.Log.Level = LevelDebug
+		m.Log.Debug("shown")
+		xt.Eq(t, "==> shown\n", bufOut.String())
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		m, bufOut, _ := newTestMaker()
+		m.Log.Prefix = "[make]"
+
+		m.Log.Info("info")
+		xt.Eq(t, "[make] info\n", bufOut.String())
+	})
+
+	t.Run("color", func(t *testing.T) {
+		m, bufOut, bufErr := newTestMaker()
+
+		m.Log.Info("not colored")
+		xt.Eq(t, "==> not colored\n", bufOut.String())
+
+		m.Log.Color = ColorAlways
+		m.Log.Error("colored")
+		xt.Eq(t, "\033[1;31mError:\033[0m colored\n", bufErr.String())
+	})
+
+	t.Run("NO_COLOR", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+		m, _, _ := newTestMaker()
+		xt.Assert(t, !m.Log.useColor(m.StdOut))
+
+		if runtime.GOOS == "windows" {
+			return
+		}
+
+		// character devices, like /dev/null, count as terminal
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		xt.OK(t, err)
+		defer func() { _ = devNull.Close() }()
+
+		xt.Assert(t, !m.Log.useColor(devNull))
+		t.Setenv("NO_COLOR", "")
+		xt.Assert(t, m.Log.useColor(devNull), "empty NO_COLOR must be ignored")
+	})
+
+	t.Run("quiet and verbose runs", func(t *testing.T) {
+		target := &Target{
+			Name:         "messages",
+			PreMessages:  []string{"starting"},
+			PostMessages: []string{"done"},
+			Do: func(target *Target) error {
+				target.Maker.Log.Debug("debugging")
+				target.Maker.Log.Warn("careful")
+				return nil
+			},
+		}
+
+		m, bufOut, bufErr := newTestMaker()
+		m.Quiet = true
+		m.registerTargets(target)
+		xt.Eq(t, 0, m.make("messages"))
+		xt.Eq(t, "", bufOut.String())
+		xt.Eq(t, "Warning: careful\n", bufErr.String())
+
+		m, bufOut, _ = newTestMaker()
+		m.Verbose = true
+		m.registerTargets(target)
+		xt.Eq(t, 0, m.make("messages"))
+		xt.Eq(t, "==> starting\n==> debugging\n==> done\n", bufOut.String())
+	})
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLogDir is the directory, relative to the working directory, in which
+// the output of each target is stored when running using Make.
+const DefaultLogDir = ".gomake/logs"
+
+// DefaultLogKeep is the number of runs of which logs are kept.
+const DefaultLogKeep = 10
+
+// logTailLines is the number of lines shown of the log of a failed target.
+const logTailLines = 20
+
+var (
+	reANSIEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	reRunID      = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}\.[0-9]{3}-[0-9]+$`)
+)
+
+// logCapture tees the output of the Maker to a log file of the target which
+// is running. When targets are nested, the innermost gets the output.
+type logCapture struct {
+	mu     sync.Mutex
+	dir    string
+	runID  string
+	keep   int
+	runDir string
+	stack  []*os.File
+	err    error
+}
+
+func newLogCapture(dir, runID string, keep int) *logCapture {
+	return &logCapture{
+		dir:   dir,
+		runID: runID,
+		keep:  keep,
+	}
+}
+
+// start opens the log file of target, creating the directory of the run when
+// needed. It returns the path of the log file.
+func (lc *logCapture) start(name string) string {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.runDir == "" && lc.err == nil {
+		runDir := filepath.Join(lc.dir, lc.runID)
+		if lc.err = os.MkdirAll(runDir, 0o750); lc.err == nil {
+			lc.runDir = runDir
+		}
+	}
+
+	var f *os.File
+	var path string
+	if lc.runDir != "" {
+		path = filepath.Join(lc.runDir, logFileName(name))
+		f, lc.err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	}
+
+	// nil is pushed when the file could not be opened so that end stays balanced
+	lc.stack = append(lc.stack, f)
+
+	if f == nil {
+		return ""
+	}
+	return path
+}
+
+// end closes the log file of the target started last.
+func (lc *logCapture) end() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if len(lc.stack) == 0 {
+		return
+	}
+
+	if f := lc.stack[len(lc.stack)-1]; f != nil {
+		_ = f.Close()
+	}
+	lc.stack = lc.stack[:len(lc.stack)-1]
+}
+
+// finish stores the ID of the run as the latest, when anything was logged,
+// and removes the logs of older runs exceeding the number to keep.
+func (lc *logCapture) finish() error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.runDir == "" {
+		return lc.err
+	}
+
+	if err := os.WriteFile(filepath.Join(lc.dir, "latest"), []byte(lc.runID+"\n"), 0o640); err != nil {
+		return err
+	}
+
+	if err := pruneRunDirs(lc.dir, lc.keep); err != nil {
+		return err
+	}
+
+	return lc.err
+}
+
+// pruneRunDirs removes the directories of the oldest runs in logDir so that
+// keep remain. Nothing is removed when keep is zero or less. Run IDs start
+// with the time the run started, so sorting them sorts the runs.
+func pruneRunDirs(logDir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		return err
+	}
+
+	var runIDs []string
+	for _, e := range entries {
+		if e.IsDir() && reRunID.MatchString(e.Name()) {
+			runIDs = append(runIDs, e.Name())
+		}
+	}
+	sort.Strings(runIDs)
+
+	for len(runIDs) > keep {
+		if err := os.RemoveAll(filepath.Join(logDir, runIDs[0])); err != nil {
+			return err
+		}
+		runIDs = runIDs[1:]
+	}
+
+	return nil
+}
+
+func (lc *logCapture) write(p []byte) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if len(lc.stack) == 0 {
+		return
+	}
+
+	if f := lc.stack[len(lc.stack)-1]; f != nil {
+		_, _ = f.Write(reANSIEscape.ReplaceAll(p, nil))
+	}
+}
+
+// writer returns a writer passing everything to w while also capturing it.
+func (lc *logCapture) writer(w io.Writer) io.Writer {
+	return &captureWriter{w: w, lc: lc}
+}
+
+type captureWriter struct {
+	w  io.Writer
+	lc *logCapture
+}
+
+func (cw *captureWriter) Write(p []byte) (int, error) {
+	cw.lc.write(p)
+	return cw.w.Write(p)
+}
+
+func (cw *captureWriter) unwrap() io.Writer {
+	return cw.w
+}
+
+// logFileName returns the name of the log file of the target named name.
+func logFileName(name string) string {
+	return strings.NewReplacer("/", "_", ":", "_", string(filepath.Separator), "_").Replace(name) + ".log"
+}
+
+// tailFile returns the last n lines of the file at path.
+func tailFile(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// latestRunDir returns the directory containing the logs of the last run.
+func latestRunDir(logDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(logDir, "latest"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("no logs available in %s", logDir)
+		}
+		return "", err
+	}
+
+	return filepath.Join(logDir, strings.TrimSpace(string(data))), nil
+}
+
+// writeErrorSummary writes for each failed target its error and the tail of
+// its log file.
+func (m *Maker) writeErrorSummary() {
+	for _, tr := range m.report.Failed() {
+		m.Log.Errorf("target %s failed: %s", tr.Name, tr.Error)
+		if tr.LogFile == "" {
+			continue
+		}
+
+		lines, err := tailFile(tr.LogFile, logTailLines)
+		if err != nil {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(m.StdErr, "--- last %d lines of %s\n", len(lines), tr.LogFile)
+		for _, line := range lines {
+			_, _ = fmt.Fprintln(m.StdErr, line)
+		}
+		_, _ = fmt.Fprintln(m.StdErr, "---")
+	}
+}
+
+// builtinLogs shows the logs of the last run. Without arguments, the log files
+// are listed; otherwise the logs of the given targets are shown.
+func (m *Maker) builtinLogs(args []string) int {
+	if m.LogDir == "" {
+		m.Log.Error("logging to files is disabled")
+		return 1
+	}
+
+	runDir, err := latestRunDir(m.LogDir)
+	if err != nil {
+		m.Log.Error(err)
+		return 1
+	}
+
+	if len(args) == 0 {
+		entries, err := os.ReadDir(runDir)
+		if err != nil {
+			m.Log.Error(err)
+			return 1
+		}
+
+		_, _ = fmt.Fprintf(m.StdOut, "Logs of last run (%s):\n", runDir)
+		for _, e := range entries {
+			_, _ = fmt.Fprintln(m.StdOut, "   "+strings.TrimSuffix(e.Name(), ".log"))
+		}
+		return 0
+	}
+
+	for _, name := range args {
+		data, err := os.ReadFile(filepath.Join(runDir, logFileName(name)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				m.Log.Errorf("no log of target %s in last run", name)
+			} else {
+				m.Log.Error(err)
+			}
+			return 1
+		}
+		_, _ = m.StdOut.Write(data)
+	}
+
+	return 0
+}
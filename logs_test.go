@@ -0,0 +1,119 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestLogs(t *testing.T) {
+	pre := &Target{
+		Name: "pre",
+		Do: func(target *Target) error {
+			_, _ = fmt.Fprintln(target.Maker.StdOut, "output of pre")
+			return nil
+		},
+	}
+
+	failing := &Target{
+		Name:        "ns:failing",
+		PreMessages: []string{"starting"},
+		PreTargets:  []*Target{pre},
+		Do: func(target *Target) error {
+			for i := 1; i <= 30; i++ {
+				_, _ = fmt.Fprintln(target.Maker.StdOut, "line", i)
+			}
+			return fmt.Errorf("failing on purpose")
+		},
+	}
+
+	logDir := t.TempDir()
+
+	var bufOut, bufErr strings.Builder
+	m := NewMaker()
+	m.StdOut = &bufOut
+	m.StdErr = &bufErr
+	m.LogDir = logDir
+	m.registerTargets(failing)
+
+	xt.Eq(t, 1, m.make("ns:failing"))
+
+	runDir := filepath.Join(logDir, m.Report().RunID)
+
+	t.Run("output is still shown", func(t *testing.T) {
+		xt.Assert(t, strings.Contains(bufOut.String(), "output of pre\n"))
+		xt.Assert(t, strings.Contains(bufOut.String(), "line 30\n"))
+	})
+
+	t.Run("log file per target", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(runDir, "pre.log"))
+		xt.OK(t, err)
+		xt.Eq(t, "output of pre\n", string(data))
+
+		data, err = os.ReadFile(filepath.Join(runDir, "ns_failing.log"))
+		xt.OK(t, err)
+		log := string(data)
+		xt.Assert(t, strings.HasPrefix(log, "==> starting\n"), log)
+		xt.Assert(t, strings.Contains(log, "line 1\n"), log)
+		xt.Assert(t, !strings.Contains(log, "output of pre"), log)
+		xt.Assert(t, strings.Contains(log, "Error: failing on purpose\n"), log)
+
+		xt.Eq(t, filepath.Join(runDir, "ns_failing.log"), m.Report().Targets[0].LogFile)
+	})
+
+	t.Run("error summary shows tail of log", func(t *testing.T) {
+		summary := bufErr.String()
+		xt.Assert(t, strings.Contains(summary, "Error: target ns:failing failed: failing on purpose\n"), summary)
+		xt.Assert(t, strings.Contains(summary, "--- last 20 lines of "), summary)
+		xt.Assert(t, strings.Contains(summary, "\nline 30\n"), summary)
+		xt.Assert(t, !strings.Contains(summary, "\nline 9\n"), summary)
+	})
+
+	t.Run("logs command", func(t *testing.T) {
+		var bufOut strings.Builder
+		m := NewMaker()
+		m.StdOut = &bufOut
+		m.LogDir = logDir
+		m.registerTargets(failing)
+
+		xt.Eq(t, 0, m.make("logs"))
+		xt.Eq(t, fmt.Sprintf("Logs of last run (%s):\n   ns_failing\n   pre\n", runDir), bufOut.String())
+
+		bufOut.Reset()
+		xt.Eq(t, 0, m.make("logs", "pre"))
+		xt.Eq(t, "output of pre\n", bufOut.String())
+
+		latest, err := latestRunDir(logDir)
+		xt.OK(t, err)
+		xt.Eq(t, runDir, latest, "logs command must not become the latest run")
+	})
+
+	t.Run("logs of old runs are removed", func(t *testing.T) {
+		logDir := t.TempDir()
+		for _, name := range []string{"20230101-120000.000-1", "20230102-120000.000-2", "20230103-120000.000-3", "other"} {
+			xt.OK(t, os.Mkdir(filepath.Join(logDir, name), 0o750))
+		}
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.LogDir = logDir
+		m.LogKeep = 2
+		m.registerTargets(pre)
+
+		xt.Eq(t, 0, m.make("pre"))
+
+		entries, err := os.ReadDir(logDir)
+		xt.OK(t, err)
+		var have []string
+		for _, e := range entries {
+			have = append(have, e.Name())
+		}
+		xt.Eq(t, []string{"20230103-120000.000-3", m.Report().RunID, "latest", "other"}, have)
+	})
+}
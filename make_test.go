@@ -37,6 +37,16 @@ func TestMake(t *testing.T) {
 		exp := `Available targets:
    go-version
    vendor
+
+Built-in commands:
+   graph [-format dot|mermaid] [target]
+      Shows the dependency graph of all targets, or of target
+   help [target|namespace]
+      Shows the available targets, or the usage of target
+   logs [target ...]
+      Shows the logs of the last run
+   shims [-check] [-just] [-dir DIR] [-cmd COMMAND] [-force]
+      Writes a Makefile, and optionally a justfile, forwarding to the targets
 `
 		var buf strings.Builder
 		m := NewMaker()
@@ -56,7 +66,7 @@ func TestMake(t *testing.T) {
 		m.registerTargets(&TargetGoVersion, &TargetVendor)
 
 		xt.Eq(t, 0, m.make("go-version"))
-		have := excludePrefixedMessages(m.msgPrefix, buf.String())
+		have := excludePrefixedMessages(m.Log.Prefix, buf.String())
 		xt.Assert(t, len(have) != 0, "expected output from target")
 		xt.Eq(t, exp, have[0])
 	})
@@ -3,48 +3,277 @@
 package gomake
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Maker struct {
-	StdOut         io.Writer
-	StdErr         io.Writer
+	StdOut io.Writer
+	StdErr io.Writer
+
+	// Reports are paths of files to which the run report is written after
+	// all targets were executed. Paths ending with `.xml` get JUnit XML, all
+	// others JSON.
+	Reports []string
+
+	// Timings, when true, shows a summary of the wall time of each target at
+	// the end of the run.
+	Timings bool
+
+	// Secrets holds the values which are masked in output, log files and
+	// run reports.
+	Secrets *Secrets
+
+	// Log is used to write messages. Its level is set when a run starts
+	// according to Verbose and Quiet.
+	Log *Logger
+
+	// Verbose, when true, shows debug messages with additional information
+	// such as the resources used by the commands of each target.
+	Verbose bool
+
+	// Quiet, when true, only shows warnings and errors.
+	Quiet bool
+
+	// ConfigFile is the path of the configuration file providing defaults for
+	// targets. No configuration is used when empty.
+	ConfigFile string
+
+	// EnvFiles are paths of .env style files from which variables are loaded
+	// into the environment of targets. When empty, DefaultEnvFile is loaded
+	// when it exists.
+	EnvFiles []string
+
+	// Profile is the name of the profile of the configuration file providing
+	// defaults on top of those configured for targets.
+	Profile string
+
+	// LogDir is the directory in which, for each run, the output of every
+	// target is stored in a log file. No log files are written when empty.
+	LogDir string
+
+	// LogKeep is the number of runs of which logs are kept in LogDir; logs of
+	// older runs are removed. All are kept when zero.
+	LogKeep int
+
+	// Plugins, when true, makes executables named `gomake-<name>` found in
+	// PluginDirs or on PATH available as target <name>; see PluginPrefix.
+	Plugins bool
+
+	// PluginDirs are directories searched for plugins before PATH. When
+	// empty, DefaultPluginDir is searched.
+	PluginDirs []string
+
+	// TraceFile is the path of the file to which the run is exported using the
+	// Chrome Trace Event Format.
+	TraceFile string
+
 	targetRegistry map[string]*Target
-	msgPrefix      string
+	report         *Report
+	config         *Config
+	env            map[string]string
+	goEnvVars      map[string]string
+	executions     map[*Target]*execution
+	parallel       int32
+	lanes          int32
+	pluginsFound   bool
+	defined        []string
+	replaced       []*Target
+	logs           *logCapture
+	maskOut        *MaskWriter
+	maskErr        *MaskWriter
+	observers      []Observer
+	mu             sync.Mutex
+	emitMu         sync.Mutex
 }
 
 func NewMaker() *Maker {
-	return &Maker{
+	m := &Maker{
 		StdOut:         os.Stdout,
 		StdErr:         os.Stderr,
 		targetRegistry: map[string]*Target{},
-		msgPrefix:      "==>",
+		LogKeep:        DefaultLogKeep,
 	}
+	m.Log = newLogger(m)
+	m.Secrets = newSecrets()
+	m.Secrets.getenv = m.getenv
+	m.observers = []Observer{&printer{maker: m}}
+
+	return m
+}
+
+// Report returns the report of the last run, or nil when nothing ran yet.
+func (m *Maker) Report() *Report {
+	return m.report
+}
+
+// setFlags defines the global command line options of the Maker in flagSet.
+func (m *Maker) setFlags(flagSet *flag.FlagSet) {
+	flagSet.Var((*stringsFlag)(&m.Reports), "report",
+		"Write run report to file; JUnit XML when file ends with .xml, otherwise JSON (repeatable)")
+	flagSet.BoolVar(&m.Timings, "timings", false, "Show the wall time of each target at the end of the run")
+	flagSet.BoolVar(&m.Verbose, "v", false, "Verbose output, showing debug messages")
+	flagSet.BoolVar(&m.Quiet, "q", false, "Quiet output, showing only warnings and errors")
+	flagSet.StringVar(&m.TraceFile, "trace", "", "Write run to file using the Chrome Trace Event Format")
+	flagSet.StringVar(&m.ConfigFile, "config", "",
+		"Configuration file (default: gomake.json, gomake.toml or gomake.yaml found walking up from working directory)")
+	flagSet.Var((*stringsFlag)(&m.EnvFiles), "env-file",
+		"Load variables from .env style file (default: "+DefaultEnvFile+" when found; repeatable)")
+	flagSet.StringVar(&m.Profile, "profile", "", "Use defaults of named profile of the configuration file")
+	flagSet.StringVar(&m.LogDir, "log-dir", DefaultLogDir,
+		"Directory in which output of targets is stored; empty to disable")
+	flagSet.IntVar(&m.LogKeep, "log-keep", DefaultLogKeep,
+		"Number of runs of which logs are kept; 0 to keep all")
+	flagSet.BoolVar(&m.Plugins, "plugins", false,
+		"Make executables named "+PluginPrefix+"<name> found in plugin directories or on PATH available as targets")
+	flagSet.Var((*stringsFlag)(&m.PluginDirs), "plugin-dir",
+		"Directory searched for plugins before PATH (default: "+DefaultPluginDir+"; repeatable)")
+	flagSet.Func("secret-env", "Mask the value of the environment variable in all output (repeatable)",
+		func(name string) error {
+			m.Secrets.AddEnv(name)
+			return nil
+		})
 }
 
 func (m *Maker) make(args ...string) int {
+	switch {
+	case m.Quiet:
+		m.Log.Level = LevelWarn
+	case m.Verbose:
+		m.Log.Level = LevelDebug
+	}
+
+	m.report = newReport()
+	m.executions = map[*Target]*execution{}
+	m.lanes = 1
+	m.goEnvVars = nil
+
+	stdOut, stdErr := m.StdOut, m.StdErr
+	if m.LogDir != "" {
+		m.logs = newLogCapture(m.LogDir, m.report.RunID, m.LogKeep)
+		m.StdOut, m.StdErr = m.logs.writer(m.StdOut), m.logs.writer(m.StdErr)
+	}
+	// masking is done first so that both terminal and log files are masked
+	m.maskOut, m.maskErr = m.Secrets.Writer(m.StdOut), m.Secrets.Writer(m.StdErr)
+	m.StdOut, m.StdErr = m.maskOut, m.maskErr
+	defer func() {
+		m.flushOutput()
+		m.StdOut, m.StdErr = stdOut, stdErr
+		m.logs = nil
+		m.maskOut, m.maskErr = nil, nil
+	}()
+
+	m.emit(Event{Kind: EventRunStart, Time: m.report.Started, Report: m.report})
+
+	exitCode := m.makeTargets(args...)
+
+	if m.logs != nil {
+		m.flushOutput()
+		if err := m.logs.finish(); err != nil {
+			m.Log.Warn("storing logs:", err)
+		}
+		m.writeErrorSummary()
+	}
+
+	m.report.Duration = time.Since(m.report.Started)
+	m.report.ExitCode = exitCode
+	m.emit(Event{Kind: EventRunEnd, Report: m.report})
+
+	report := m.Secrets.maskReport(m.report)
+
+	for _, path := range m.Reports {
+		if err := report.WriteFile(path); err != nil {
+			m.Log.Error(err)
+			exitCode = 1
+		}
+	}
+
+	if m.TraceFile != "" {
+		if err := report.WriteChromeTrace(m.TraceFile); err != nil {
+			m.Log.Error(err)
+			exitCode = 1
+		}
+	}
+
+	if m.Timings && len(m.report.Targets) > 0 {
+		_, _ = fmt.Fprintln(m.StdOut)
+		_ = report.WriteTimings(m.StdOut)
+	}
+
+	return exitCode
+}
+
+func (m *Maker) makeTargets(args ...string) int {
+	m.discoverPlugins()
+
+	if err := m.loadEnvFiles(); err != nil {
+		m.Log.Error(err)
+		return 1
+	}
+
+	// targets can be defined in the configuration file
+	if err := m.loadConfig(); err != nil {
+		m.Log.Error(err)
+		return 1
+	}
+
 	if len(m.targetRegistry) == 0 {
-		m.PrintlnError("no targets available")
+		m.Log.Error("no targets available")
 		return 1
 	}
 
 	if len(args) == 0 {
-		m.Print(helpAvailableTargets(m))
+		m.describePlugins()
+		_, _ = fmt.Fprint(m.StdOut, helpAvailableTargets(m))
 		return 0
 	}
 
+	args = m.joinNamespaceArgs(args)
 	targetName := args[0]
 
-	if targetName == "help" {
-		m.Print(helpAvailableTargets(m))
+	switch targetName {
+	case "help":
+		m.describePlugins()
+		if len(args) > 1 {
+			name := strings.Join(args[1:], NamespaceSeparator)
+			target, ok := m.lookupTarget(name)
+			if !ok && m.isNamespace(name) {
+				_, _ = fmt.Fprint(m.StdOut, helpNamespace(m, name))
+				return 0
+			}
+			if !ok {
+				m.Log.Errorf("target %s not available\n\n%s", name, helpAvailableTargets(m))
+				return 1
+			}
+			_, _ = fmt.Fprint(m.StdOut, helpTarget(target))
+			return 0
+		}
+		_, _ = fmt.Fprint(m.StdOut, helpAvailableTargets(m))
 		return 0
 	}
 
-	target, ok := m.targetRegistry[targetName]
+	// registered targets take precedence over the other built-in commands
+	if _, ok := m.targetRegistry[targetName]; !ok {
+		switch targetName {
+		case "logs":
+			return m.builtinLogs(args[1:])
+		case "graph":
+			return m.builtinGraph(args[1:])
+		case "shims":
+			return m.builtinShims(args[1:])
+		}
+	}
+
+	target, ok := m.lookupTarget(targetName)
 	if !ok {
-		m.PrintfError("target %s not available\n\n%s\n", targetName, helpAvailableTargets(m))
+		m.Log.Errorf("target %s not available\n\n%s", targetName, helpAvailableTargets(m))
 		return 1
 	}
 
@@ -52,22 +281,85 @@ func (m *Maker) make(args ...string) int {
 		target.FlagArgs = args[1:]
 	}
 
-	return m.run(target)
+	if profile, ok := extractProfile(target); ok {
+		if profile == "" {
+			m.Log.Errorf("%s: flag needs an argument: -profile", target.Name)
+			return 1
+		}
+		m.Profile = profile
+	}
+	if err := m.checkProfile(); err != nil {
+		m.Log.Error(err)
+		return 1
+	}
+
+	if cycle := dependencyCycle(target); cycle != nil {
+		m.Log.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		return 1
+	}
+
+	return m.run(1, target)
+}
+
+// dependencyCycle returns the names of the targets forming a cycle through
+// the pre-targets, deferred targets or combined targets of target, starting
+// and ending with the same target. It returns nil when there is no cycle.
+func dependencyCycle(target *Target) []string {
+	var path []*Target
+	done := map[*Target]bool{}
+
+	var visit func(t *Target) []string
+	visit = func(t *Target) []string {
+		for i, p := range path {
+			if p == t {
+				var names []string
+				for _, c := range path[i:] {
+					names = append(names, c.Name)
+				}
+				return append(names, t.Name)
+			}
+		}
+		if done[t] {
+			return nil
+		}
+
+		path = append(path, t)
+		for _, e := range targetEdges(t) {
+			if cycle := visit(e.to); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		done[t] = true
+		return nil
+	}
+
+	return visit(target)
 }
 
 func (m *Maker) registerTargets(targets ...*Target) {
 	for _, target := range targets {
-		_, ok := m.targetRegistry[target.Name]
-		if ok {
-			FExitErrorf(m.StdErr, "target %s cannot be registered more than once; was ", target.Name)
+		if registered, ok := m.targetRegistry[target.Name]; ok {
+			if registered == target {
+				continue
+			}
+			m.Log.Errorf("target %s cannot be registered more than once", target.Name)
+		}
+		if strings.Contains(target.Name, "%") && !isPattern(target.Name) {
+			m.Log.Errorf("pattern target %s must contain %% only once", target.Name)
 		}
 		m.targetRegistry[target.Name] = target
+		if target.registerChildren {
+			m.registerTargets(target.children...)
+		}
 	}
 }
 
-func (m *Maker) run(targets ...*Target) int {
+// run executes targets one after the other, stopping at the first which
+// fails. Targets are shown in lane of the run trace.
+func (m *Maker) run(lane int, targets ...*Target) int {
 	for _, target := range targets {
-		if ret := m.runTarget(target); ret > 0 {
+		if ret := m.runTarget(target, lane); ret > 0 {
 			return ret
 		}
 	}
@@ -75,61 +367,221 @@ func (m *Maker) run(targets ...*Target) int {
 	return 0
 }
 
-func (m *Maker) runTarget(target *Target) int {
-	target.Maker = m
-	if target.HandleFlags != nil {
-		if _, err := target.HandleFlags(target); err != nil {
-			m.PrintlnError(err)
-			return 1
+// execution is the execution of a target during a run.
+type execution struct {
+	done     chan struct{}
+	exitCode int
+}
+
+// runTarget executes target, unless it was already executed during the run, in
+// which case the exit code of that execution is returned once it finished.
+// The target is shown in lane of the run trace.
+func (m *Maker) runTarget(target *Target, lane int) int {
+	return m.startTarget(target, lane, false)
+}
+
+// runDeferred executes targets one after the other, stopping at the first
+// which fails. Unlike pre-targets, deferred targets are executed again when
+// they were already executed during the run, so that a target such as one
+// stopping a container can be used both before and after a target.
+func (m *Maker) runDeferred(lane int, targets ...*Target) int {
+	for _, target := range targets {
+		if ret := m.startTarget(target, lane, true); ret > 0 {
+			return ret
 		}
 	}
 
-	for _, msg := range target.PreMessages {
-		m.Println(m.msgPrefix, msg)
-	}
+	return 0
+}
 
-	defer func() {
-		for _, msg := range target.PostMessages {
-			m.Println(m.msgPrefix, msg)
+// startTarget executes target, showing it in lane of the run trace. When the
+// target was already executed during the run, the exit code of that execution
+// is returned once it finished, unless again is true.
+func (m *Maker) startTarget(target *Target, lane int, again bool) int {
+	m.mu.Lock()
+	if e, ok := m.executions[target]; ok {
+		m.mu.Unlock()
+		<-e.done
+		if !again {
+			return e.exitCode
 		}
-	}()
+		m.mu.Lock()
+	}
+	e := &execution{done: make(chan struct{})}
+	m.executions[target] = e
+	target.Maker = m
+	target.report = m.report.addTarget(target.Name)
+	target.report.lane = lane
+	m.mu.Unlock()
+
+	defer close(e.done)
+	e.exitCode = m.executeTarget(target)
+	return e.exitCode
+}
+
+func (m *Maker) executeTarget(target *Target) int {
+	// output of targets running in parallel ends up in the log file of the
+	// target which started them
+	if m.logs != nil && atomic.LoadInt32(&m.parallel) == 0 {
+		m.flushOutput()
+		target.report.LogFile = m.logs.start(target.Name)
+		defer func() {
+			m.flushOutput()
+			m.logs.end()
+		}()
+	}
+	m.emit(Event{Kind: EventTargetStart, Time: target.report.Started, Target: target, TargetReport: target.report})
+
+	status, err := m.doTarget(target)
+	target.report.finish(status, err)
+
+	kind := EventTargetSuccess
+	switch status {
+	case StatusFailed:
+		kind = EventTargetFailure
+	case StatusSkipped:
+		kind = EventTargetSkip
+	case StatusSucceeded:
+	}
+	m.emit(Event{Kind: kind, Target: target, TargetReport: target.report, Err: err})
+
+	if status == StatusFailed || (status == StatusSkipped && !errors.Is(err, ErrSkip)) {
+		return 1
+	}
+	return 0
+}
+
+// flushOutput writes output which was held back while masking secrets.
+func (m *Maker) flushOutput() {
+	if m.maskOut != nil {
+		_ = m.maskOut.Flush()
+		_ = m.maskErr.Flush()
+	}
+}
+
+// doTarget handles the flags of target, runs its pre-targets and executes
+// it. Flags are handled before the pre-targets, so that invalid flags do not
+// execute them. Templates referring to outputs of pre-targets are expanded
+// once these finished, after which flags are handled again. Once the
+// pre-targets started, deferred targets are always run.
+func (m *Maker) doTarget(target *Target) (TargetStatus, error) {
+	restore, configured, err := m.configure(target)
+	if err != nil {
+		return StatusFailed, err
+	}
+	defer restore()
+
+	restoreExpanded, needOutputs, err := m.expand(target, configured, true)
+	if err != nil {
+		return StatusFailed, err
+	}
+	defer restoreExpanded()
+
+	flags := cloneValue(target.Flags)
+	if err := m.handleFlags(target); err != nil {
+		return StatusFailed, err
+	}
 
 	defer func() {
-		m.run(target.DeferredTargets...)
+		m.runDeferred(target.report.lane, target.DeferredTargets...)
 	}()
 
-	if ret := m.run(target.PreTargets...); ret > 0 {
-		return ret
+	if ret := m.run(target.report.lane, target.PreTargets...); ret > 0 {
+		return StatusSkipped, fmt.Errorf("pre-target of %s failed", target.Name)
+	}
+
+	if needOutputs {
+		target.Flags = flags
+		restoreOutputs, _, err := m.expand(target, configured, false)
+		if err != nil {
+			return StatusFailed, err
+		}
+		defer restoreOutputs()
+
+		if err := m.handleFlags(target); err != nil {
+			return StatusFailed, err
+		}
 	}
 
 	if err := target.Do(target); err != nil {
-		m.PrintlnError(err)
-		return 1
+		if errors.Is(err, ErrSkip) {
+			return StatusSkipped, err
+		}
+		return StatusFailed, err
 	}
 
-	return 0
+	return StatusSucceeded, nil
+}
+
+// handleFlags calls HandleFlags of target, when set, and registers the values
+// of secret flags.
+func (m *Maker) handleFlags(target *Target) error {
+	if target.HandleFlags == nil {
+		return nil
+	}
+
+	flagSet, err := target.HandleFlags(target)
+	if err != nil {
+		return err
+	}
+	m.Secrets.addFlagValues(target, flagSet)
+	return nil
 }
 
+// PrintfError writes an error message.
+//
+// Deprecated: use Maker.Log.Errorf.
 func (m *Maker) PrintfError(format string, a ...any) {
-	_, _ = fmt.Fprintf(m.StdErr, "Error: "+format, a...)
+	m.Log.Errorf(format, a...)
 }
 
+// PrintError writes an error message.
+//
+// Deprecated: use Maker.Log.Error.
 func (m *Maker) PrintError(a ...any) {
-	_, _ = fmt.Fprint(m.StdErr, append([]any{"Error: "}, a...)...)
+	m.Log.Error(fmt.Sprint(a...))
 }
 
+// PrintlnError writes an error message.
+//
+// Deprecated: use Maker.Log.Error.
 func (m *Maker) PrintlnError(a ...any) {
-	_, _ = fmt.Fprintln(m.StdErr, append([]any{"Error:"}, a...)...)
+	m.Log.Error(a...)
 }
 
+// Printf writes to the standard output of the Maker.
+//
+// Deprecated: use Maker.Log for messages, or write output to Maker.StdOut.
 func (m *Maker) Printf(format string, a ...any) {
 	_, _ = fmt.Fprintf(m.StdOut, format, a...)
 }
 
+// Print writes to the standard output of the Maker.
+//
+// Deprecated: use Maker.Log for messages, or write output to Maker.StdOut.
 func (m *Maker) Print(a ...any) {
 	_, _ = fmt.Fprint(m.StdOut, a...)
 }
 
+// Println writes to the standard output of the Maker.
+//
+// Deprecated: use Maker.Log for messages, or write output to Maker.StdOut.
 func (m *Maker) Println(a ...any) {
 	_, _ = fmt.Fprintln(m.StdOut, a...)
 }
+
+// stringsFlag is a flag.Value which can be given multiple times on the
+// command line, collecting all values.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"sort"
+	"strings"
+)
+
+// NamespaceSeparator separates the namespace from the name of a target, as
+// in `docker:build`.
+const NamespaceSeparator = ":"
+
+// Mount registers targets with the default Maker under namespace.
+func Mount(namespace string, targets ...*Target) {
+	defaultMake.Mount(namespace, targets...)
+}
+
+// Mount registers copies of targets under namespace, so that, for example,
+// target build mounted under docker is executed as `docker:build`. The
+// pre-targets and deferred targets of targets are copied and prefixed as
+// well, so that packs of targets depending on each other can be mounted
+// more than once, and next to targets with the same name.
+func (m *Maker) Mount(namespace string, targets ...*Target) {
+	if namespace == "" || strings.ContainsAny(namespace, " \t") {
+		m.Log.Errorf("namespace %q cannot be used to mount targets", namespace)
+		return
+	}
+
+	seen := map[*Target]*Target{}
+	mounted := make([]*Target, len(targets))
+	for i, t := range targets {
+		mounted[i] = t.clone(seen)
+	}
+
+	prefix := namespace + NamespaceSeparator
+	for _, c := range seen {
+		c.Name = prefix + c.Name
+		if c.pattern != "" {
+			c.pattern = prefix + c.pattern
+		}
+	}
+
+	m.registerTargets(mounted...)
+}
+
+// MountMaker registers the targets of sub under namespace, making sub a
+// sub-command of m: target build of sub is executed as `namespace:build`, or
+// `namespace build`. Targets are executed by m using its configuration.
+func (m *Maker) MountMaker(namespace string, sub *Maker) {
+	// children of matrix targets are mounted together with their group
+	children := map[*Target]bool{}
+	for _, t := range sub.targetRegistry {
+		if t.registerChildren {
+			for _, c := range t.children {
+				children[c] = true
+			}
+		}
+	}
+
+	var targets []*Target
+	for _, name := range sortedKeys(sub.targetRegistry) {
+		if t := sub.targetRegistry[name]; !children[t] {
+			targets = append(targets, t)
+		}
+	}
+
+	m.Mount(namespace, targets...)
+}
+
+// namespaces returns the namespaces of registered targets, including those
+// in which namespaces are nested, sorted by name.
+func (m *Maker) namespaces() []string {
+	seen := map[string]bool{}
+	for name := range m.targetRegistry {
+		parts := strings.Split(name, NamespaceSeparator)
+		for i := 1; i < len(parts); i++ {
+			seen[strings.Join(parts[:i], NamespaceSeparator)] = true
+		}
+	}
+
+	res := make([]string, 0, len(seen))
+	for ns := range seen {
+		res = append(res, ns)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (m *Maker) isNamespace(name string) bool {
+	for _, ns := range m.namespaces() {
+		if ns == name {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceOf returns the namespace of the target called name, which is empty
+// for targets not mounted.
+func namespaceOf(name string) string {
+	if i := strings.LastIndex(name, NamespaceSeparator); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// joinNamespaceArgs returns args with leading namespaces joined with the name
+// of the target following them, so that `docker build` becomes
+// `docker:build`.
+func (m *Maker) joinNamespaceArgs(args []string) []string {
+	for len(args) > 1 {
+		if _, ok := m.lookupTarget(args[0]); ok || !m.isNamespace(args[0]) {
+			break
+		}
+		args = append([]string{args[0] + NamespaceSeparator + args[1]}, args[2:]...)
+	}
+	return args
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestMaker_Mount(t *testing.T) {
+	var got []string
+	record := func(target *Target) error {
+		got = append(got, target.Name)
+		return nil
+	}
+
+	newPack := func() []*Target {
+		version := NewTarget("version", record, WithDescription("Shows version"))
+		build := NewTarget("build", record, WithDescription("Builds"), WithPreTargets(version))
+		return []*Target{build, version}
+	}
+
+	newMaker := func() (*Maker, *strings.Builder) {
+		m := NewMaker()
+		stdOut := &strings.Builder{}
+		m.StdOut = stdOut
+		m.StdErr = &strings.Builder{}
+		m.LogDir = ""
+		return m, stdOut
+	}
+
+	t.Run("targets and pre-targets are prefixed", func(t *testing.T) {
+		got = nil
+		pack := newPack()
+		m, _ := newMaker()
+		m.registerTargets(NewTarget("build", record))
+		m.Mount("docker", pack...)
+		m.Mount("go", pack...)
+
+		xt.Eq(t, 0, m.make("docker:build"))
+		xt.Eq(t, []string{"docker:version", "docker:build"}, got)
+		xt.Eq(t, "build", pack[0].Name)
+
+		got = nil
+		xt.Eq(t, 0, m.make("go", "build"))
+		xt.Eq(t, []string{"go:version", "go:build"}, got)
+	})
+
+	t.Run("nested makers", func(t *testing.T) {
+		got = nil
+		cache := NewMaker()
+		cache.registerTargets(NewTarget("prune", record))
+
+		docker := NewMaker()
+		docker.registerTargets(newPack()...)
+		docker.MountMaker("cache", cache)
+		docker.registerTargets(NewMatrixTarget("push", []string{"REGISTRY"}, [][]string{{"eu"}, {"us"}}, record))
+
+		m, stdOut := newMaker()
+		m.registerTargets(NewTarget("lint", record))
+		m.MountMaker("docker", docker)
+
+		xt.Eq(t, 0, m.make("docker", "cache", "prune"))
+		xt.Eq(t, 0, m.make("docker:push-us"))
+		xt.Eq(t, []string{"docker:cache:prune", "docker:push-us"}, got)
+
+		stdOut.Reset()
+		xt.Eq(t, 0, m.make())
+		xt.Eq(t, `Available targets:
+   lint
+
+docker:
+   docker:build
+      Builds
+   docker:push
+   docker:push-eu
+      (REGISTRY=eu)
+   docker:push-us
+      (REGISTRY=us)
+   docker:version
+      Shows version
+
+docker:cache:
+   docker:cache:prune
+
+Built-in commands:
+`+helpBuiltins(m), stdOut.String())
+
+		stdOut.Reset()
+		xt.Eq(t, 0, m.make("help", "docker", "cache"))
+		xt.Eq(t, `Available targets in docker:cache:
+   docker:cache:prune
+`, stdOut.String())
+
+		stdOut.Reset()
+		xt.Eq(t, 0, m.make("help", "docker", "build"))
+		xt.Eq(t, "Usage: docker:build [flags]\n   Builds\n", stdOut.String())
+	})
+
+	t.Run("mounted pattern targets", func(t *testing.T) {
+		got = nil
+		m, _ := newMaker()
+		m.Mount("go", NewTarget("build-%", record))
+		xt.Eq(t, 0, m.make("go", "build-api"))
+		xt.Eq(t, []string{"go:build-api"}, got)
+	})
+
+	t.Run("invalid namespace", func(t *testing.T) {
+		m, _ := newMaker()
+		stdErr := &strings.Builder{}
+		m.StdErr = stdErr
+		m.Mount("", newPack()...)
+		xt.Assert(t, strings.Contains(stdErr.String(), `namespace "" cannot be used to mount targets`))
+		xt.Eq(t, 0, len(m.targetRegistry))
+	})
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// EventKind identifies what happened during a run.
+type EventKind int
+
+const (
+	EventRunStart EventKind = iota
+	EventRunEnd
+	EventTargetStart
+	EventTargetSkip
+	EventTargetSuccess
+	EventTargetFailure
+	EventCommandStart
+	EventCommandEnd
+	EventOutput
+)
+
+var eventKindNames = map[EventKind]string{
+	EventRunStart:      "run-start",
+	EventRunEnd:        "run-end",
+	EventTargetStart:   "target-start",
+	EventTargetSkip:    "target-skip",
+	EventTargetSuccess: "target-success",
+	EventTargetFailure: "target-failure",
+	EventCommandStart:  "command-start",
+	EventCommandEnd:    "command-end",
+	EventOutput:        "output",
+}
+
+func (k EventKind) String() string {
+	return eventKindNames[k]
+}
+
+// Output streams of commands as reported in events of kind EventOutput.
+const (
+	StreamStdOut = "stdout"
+	StreamStdErr = "stderr"
+)
+
+// Event is passed on to observers. Which fields are set depends on Kind:
+//   - run events carry Report, which for EventRunEnd is complete;
+//   - target events carry Target and TargetReport, and Err when the target
+//     failed or was skipped;
+//   - command events carry Target and Command, and Err when the command failed;
+//   - output events carry Target, Command, Stream and one Line of output
+//     without the line ending.
+type Event struct {
+	Kind         EventKind
+	Time         time.Time
+	Report       *Report
+	Target       *Target
+	TargetReport *TargetReport
+	Command      *CommandReport
+	Stream       string
+	Line         string
+	Err          error
+}
+
+// Observer receives the events of a run of a Maker. Events are delivered one
+// at a time, but an observer must not run targets or commands itself. It can
+// read outputs and reports, for example using Maker.Output.
+type Observer interface {
+	OnEvent(event Event)
+}
+
+// ObserverFunc is an adapter to allow the use of ordinary functions as
+// Observer.
+type ObserverFunc func(event Event)
+
+func (f ObserverFunc) OnEvent(event Event) {
+	f(event)
+}
+
+// AddObserver adds observer to the default Maker.
+func AddObserver(observer Observer) {
+	defaultMake.AddObserver(observer)
+}
+
+// AddObserver adds observer, which will receive events of subsequent runs.
+func (m *Maker) AddObserver(observer Observer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.observers = append(m.observers, observer)
+}
+
+// observesOutput returns whether observers other than the printer were
+// added, which might need the output of commands.
+func (m *Maker) observesOutput() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, o := range m.observers {
+		if _, ok := o.(*printer); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// emit delivers event to the observers. The observers are called without
+// holding mu, so that they can read outputs and reports.
+func (m *Maker) emit(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	m.mu.Lock()
+	observers := make([]Observer, len(m.observers))
+	copy(observers, m.observers)
+	m.mu.Unlock()
+
+	m.emitMu.Lock()
+	defer m.emitMu.Unlock()
+
+	for _, o := range observers {
+		o.OnEvent(event)
+	}
+}
+
+// printer is the Observer showing the pre- and post-messages of targets, and
+// errors of targets which failed.
+type printer struct {
+	maker *Maker
+}
+
+func (p *printer) OnEvent(event Event) {
+	m := p.maker
+
+	switch event.Kind {
+	case EventTargetStart:
+		for _, msg := range event.Target.PreMessages {
+			m.Log.Info(msg)
+		}
+	case EventTargetSuccess, EventTargetFailure, EventTargetSkip:
+		switch {
+		case event.Kind == EventTargetFailure:
+			m.Log.Error(event.Err)
+		case event.Kind == EventTargetSkip && event.Err != nil:
+			m.Log.Info(event.Err)
+		}
+		if len(event.TargetReport.Commands) > 0 {
+			m.Log.Debug("resource usage:", event.TargetReport.ResourceUsage.String())
+		}
+		for _, msg := range event.Target.PostMessages {
+			m.Log.Info(msg)
+		}
+	case EventRunStart, EventRunEnd, EventCommandStart, EventCommandEnd, EventOutput:
+	}
+}
+
+// lineWriter passes everything written to w, and emits an event for each
+// line written to it.
+type lineWriter struct {
+	w      io.Writer
+	buf    []byte
+	onLine func(line string)
+}
+
+func newLineWriter(w io.Writer, onLine func(line string)) *lineWriter {
+	if w == nil {
+		w = io.Discard
+	}
+	return &lineWriter{w: w, onLine: onLine}
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	n, err := lw.w.Write(p)
+
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		lw.onLine(string(bytes.TrimSuffix(lw.buf[:i], []byte("\r"))))
+		lw.buf = lw.buf[i+1:]
+	}
+
+	return n, err
+}
+
+// flush emits what remains as last line when the output did not end with
+// a new line.
+func (lw *lineWriter) flush() {
+	if len(lw.buf) > 0 {
+		lw.onLine(string(lw.buf))
+		lw.buf = nil
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestObserver(t *testing.T) {
+	collect := func(m *Maker) *[]Event {
+		var events []Event
+		m.AddObserver(ObserverFunc(func(event Event) {
+			events = append(events, event)
+		}))
+		return &events
+	}
+
+	kinds := func(events []Event) []string {
+		var res []string
+		for _, e := range events {
+			res = append(res, e.Kind.String())
+		}
+		return res
+	}
+
+	t.Run("events of successful run", func(t *testing.T) {
+		target := &Target{
+			Name: "version",
+			Do: func(target *Target) error {
+				return target.RunCmd(exec.Command("go", "version"))
+			},
+		}
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		events := collect(m)
+		m.registerTargets(target)
+
+		xt.Eq(t, 0, m.make("version"))
+
+		xt.Eq(t, []string{
+			"run-start", "target-start", "command-start", "output",
+			"command-end", "target-success", "run-end",
+		}, kinds(*events))
+
+		output := (*events)[3]
+		xt.Eq(t, StreamStdOut, output.Stream)
+		xt.Eq(t, fmt.Sprintf("go version %s %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH),
+			output.Line)
+		xt.Eq(t, target, output.Target)
+	})
+
+	t.Run("events of failing and skipped targets", func(t *testing.T) {
+		failing := &Target{
+			Name: "failing",
+			Do: func(target *Target) error {
+				return fmt.Errorf("failing on purpose")
+			},
+		}
+		target := &Target{
+			Name:       "skipped",
+			PreTargets: []*Target{failing},
+			Do: func(target *Target) error {
+				return nil
+			},
+		}
+
+		var bufErr strings.Builder
+		m := NewMaker()
+		m.StdErr = &bufErr
+		events := collect(m)
+		m.registerTargets(target)
+
+		xt.Eq(t, 1, m.make("skipped"))
+
+		xt.Eq(t, []string{
+			"run-start", "target-start", "target-start", "target-failure", "target-skip", "run-end",
+		}, kinds(*events))
+		xt.Eq(t, "failing on purpose", (*events)[3].Err.Error())
+		xt.Eq(t, "Error: failing on purpose\n", bufErr.String())
+	})
+
+	t.Run("files are passed on unless observed", func(t *testing.T) {
+		f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+		xt.OK(t, err)
+		defer func() { _ = f.Close() }()
+
+		var cmd *exec.Cmd
+		target := &Target{
+			Name: "version",
+			Do: func(target *Target) error {
+				cmd = exec.Command("go", "version")
+				cmd.Stdout, cmd.Stderr = f, f
+				return target.RunCmd(cmd)
+			},
+		}
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.registerTargets(target)
+
+		xt.Eq(t, 0, m.make("version"))
+		xt.Eq(t, f, cmd.Stdout.(*os.File))
+		xt.Eq(t, f, cmd.Stderr.(*os.File))
+
+		events := collect(m)
+		xt.Eq(t, 0, m.make("version"))
+		_, ok := cmd.Stdout.(*lineWriter)
+		xt.Assert(t, ok, "output must be captured for observers")
+		xt.Eq(t, "output", (*events)[3].Kind.String())
+	})
+
+	t.Run("observers can read outputs", func(t *testing.T) {
+		target := &Target{
+			Name: "version",
+			Do: func(target *Target) error {
+				target.SetOutput("version", "1.2.3")
+				return nil
+			},
+		}
+
+		var have []any
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.AddObserver(ObserverFunc(func(event Event) {
+			if event.Kind == EventTargetSuccess {
+				v, _ := event.Target.Output("version")
+				w, _ := m.Output("version", "version")
+				have = append(have, v, w)
+			}
+		}))
+		m.registerTargets(target)
+
+		xt.Eq(t, 0, m.make("version"))
+		xt.Eq(t, []any{"1.2.3", "1.2.3"}, have)
+	})
+
+	t.Run("output is observed by any observer but the printer", func(t *testing.T) {
+		m := NewMaker()
+		xt.Assert(t, !m.observesOutput())
+
+		m.observers = []Observer{ObserverFunc(func(event Event) {})}
+		xt.Assert(t, m.observesOutput())
+
+		m.observers = []Observer{ObserverFunc(func(event Event) {}), &printer{maker: m}}
+		xt.Assert(t, m.observesOutput())
+	})
+}
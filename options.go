@@ -0,0 +1,125 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"io"
+)
+
+// TargetOption customizes a target created using NewTarget or one of the
+// constructors of the stock targets, such as NewDockerBuildTarget.
+type TargetOption func(target *Target)
+
+// NewTarget returns a new target called name executing do, customized
+// using opts.
+func NewTarget(name string, do func(target *Target) error, opts ...TargetOption) *Target {
+	return newTarget(&Target{Name: name, Do: do}, opts...)
+}
+
+// newTarget returns a clone of base customized using opts.
+func newTarget(base *Target, opts ...TargetOption) *Target {
+	target := base.Clone()
+	for _, opt := range opts {
+		opt(target)
+	}
+	return target
+}
+
+// WithName sets the name of the target.
+func WithName(name string) TargetOption {
+	return func(target *Target) {
+		target.Name = name
+	}
+}
+
+// WithDescription sets the description of the target shown in help.
+func WithDescription(description string) TargetOption {
+	return func(target *Target) {
+		target.Description = description
+	}
+}
+
+// WithFlag stores value in the flags of the target using key. The key is
+// the one used by the target, which is not necessarily the name of the flag
+// on the command line.
+func WithFlag(key string, value any) TargetOption {
+	return func(target *Target) {
+		if target.Flags == nil {
+			target.Flags = map[string]any{}
+		}
+		target.Flags[key] = value
+	}
+}
+
+// WithFlags stores each of flags in the flags of the target.
+func WithFlags(flags map[string]any) TargetOption {
+	return func(target *Target) {
+		for k, v := range flags {
+			WithFlag(k, v)(target)
+		}
+	}
+}
+
+// WithSetting sets the setting name of the target to value.
+func WithSetting(name string, value any) TargetOption {
+	return func(target *Target) {
+		if target.Settings == nil {
+			target.Settings = map[string]any{}
+		}
+		target.Settings[name] = value
+	}
+}
+
+// WithPreTargets adds targets which are executed before the target.
+func WithPreTargets(targets ...*Target) TargetOption {
+	return func(target *Target) {
+		target.PreTargets = append(target.PreTargets, targets...)
+	}
+}
+
+// WithDeferredTargets adds targets which are executed after the target, even
+// when it failed.
+func WithDeferredTargets(targets ...*Target) TargetOption {
+	return func(target *Target) {
+		target.DeferredTargets = append(target.DeferredTargets, targets...)
+	}
+}
+
+// WithMessages sets the messages shown before and after executing the target.
+func WithMessages(pre, post []string) TargetOption {
+	return func(target *Target) {
+		target.PreMessages, target.PostMessages = pre, post
+	}
+}
+
+// WithWorkDir sets the directory in which the commands of the target run.
+func WithWorkDir(dir string) TargetOption {
+	return func(target *Target) {
+		target.WorkDir = dir
+	}
+}
+
+// WithEnv sets the environment of the commands of the target.
+func WithEnv(env Env) TargetOption {
+	return func(target *Target) {
+		target.Env = env
+	}
+}
+
+// WithEnvVar adds, or replaces, the environment variable name of the commands
+// of the target.
+func WithEnvVar(name, value string) TargetOption {
+	return func(target *Target) {
+		if target.Env.Set == nil {
+			target.Env.Set = map[string]string{}
+		}
+		target.Env.Set[name] = value
+	}
+}
+
+// WithStdin sets the standard input of the commands of the target.
+func WithStdin(r io.Reader) TargetOption {
+	return func(target *Target) {
+		target.Stdin = r
+	}
+}
@@ -7,14 +7,23 @@ import (
 	"io"
 )
 
+// FExitErrorf writes an error message to w.
+//
+// Deprecated: use Maker.Log.Errorf.
 func FExitErrorf(w io.Writer, format string, a ...any) {
 	FExitError(w, fmt.Sprintf(format, a...))
 }
 
+// FExitError writes an error message to w.
+//
+// Deprecated: use Maker.Log.Error.
 func FExitError(w io.Writer, a ...any) {
 	FPrintError(w, a...)
 }
 
+// FPrintError writes an error message to w.
+//
+// Deprecated: use Maker.Log.Error.
 func FPrintError(w io.Writer, a ...any) {
 	_, _ = fmt.Fprintln(w, "Error:", fmt.Sprint(a...))
 }
@@ -0,0 +1,110 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import "fmt"
+
+// SetOutput publishes value under name so that targets executed later during
+// the run can use it, for example in templates as
+// `{{ index .Outputs "go-version" "version" }}`; the shorter
+// `{{ .Outputs.target.name }}` only works for names without hyphens.
+// Outputs are recorded in the run report. When
+// the target is not executed as part of a run, for example when calling Do in
+// a test, the value is only stored with the target; see Target.Output.
+func (t *Target) SetOutput(name string, value any) {
+	if t.Maker == nil || t.report == nil {
+		if t.outputs == nil {
+			t.outputs = map[string]any{}
+		}
+		t.outputs[name] = value
+		return
+	}
+
+	m := t.Maker
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t.report.Outputs == nil {
+		t.report.Outputs = map[string]any{}
+	}
+	t.report.Outputs[name] = value
+}
+
+// Output returns the value the target published as name during its last
+// execution, or using SetOutput outside a run.
+func (t *Target) Output(name string) (any, bool) {
+	if t.Maker == nil || t.report == nil {
+		v, ok := t.outputs[name]
+		return v, ok
+	}
+
+	t.Maker.mu.Lock()
+	defer t.Maker.mu.Unlock()
+
+	v, ok := t.report.Outputs[name]
+	return v, ok
+}
+
+// Output returns the value published as name by the target executed during
+// the current, or last, run. When the target was executed more than once,
+// the value of the last execution is returned.
+func (m *Maker) Output(target, name string) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.report == nil {
+		return nil, false
+	}
+
+	for i := len(m.report.Targets) - 1; i >= 0; i-- {
+		tr := m.report.Targets[i]
+		if tr.Name != target {
+			continue
+		}
+		if v, ok := tr.Outputs[name]; ok {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// Output returns the value published as name by target, which must be of
+// type T.
+func Output[T any](m *Maker, target, name string) (T, error) {
+	var res T
+
+	v, ok := m.Output(target, name)
+	if !ok {
+		return res, fmt.Errorf("output %s of target %s not available", name, target)
+	}
+
+	res, ok = v.(T)
+	if !ok {
+		return res, fmt.Errorf("output %s of target %s is %T, not %T", name, target, v, res)
+	}
+
+	return res, nil
+}
+
+// outputs returns the outputs published so far during the run by target name.
+func (m *Maker) outputs() map[string]map[string]any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := map[string]map[string]any{}
+	if m.report == nil {
+		return res
+	}
+
+	for _, tr := range m.report.Targets {
+		if res[tr.Name] == nil {
+			res[tr.Name] = map[string]any{}
+		}
+		for k, v := range tr.Outputs {
+			res[tr.Name][k] = v
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestOutputs(t *testing.T) {
+	targetVersion := &Target{
+		Name: "version",
+		Do: func(target *Target) error {
+			target.SetOutput("version", "1.2.3")
+			target.SetOutput("build", 42)
+			target.SetOutput("token", "s3cr3t-token")
+			return nil
+		},
+	}
+
+	var haveTag any
+	var haveBuild int
+	targetBuild := &Target{
+		Name:       "build",
+		PreTargets: []*Target{targetVersion},
+		Flags:      map[string]any{"tag": "v{{ .Outputs.version.version }}"},
+		DefineFlags: func(flagSet *flag.FlagSet) {
+			flagSet.String("tag", "", "Image tag")
+		},
+		HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+			flagSet, err := target.ParseFlags()
+			if err != nil {
+				return nil, err
+			}
+			target.StoreFlag(flagSet, "tag", "tag")
+			return flagSet, nil
+		},
+		Do: func(target *Target) error {
+			haveTag = target.Flags["tag"]
+
+			var err error
+			haveBuild, err = Output[int](target.Maker, "version", "build")
+			return err
+		},
+	}
+
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+
+	m := NewMaker()
+	m.StdOut = &strings.Builder{}
+	m.Reports = []string{reportFile}
+	m.Secrets.Add("s3cr3t-token")
+	m.registerTargets(targetBuild)
+
+	xt.Eq(t, 0, m.make("build"))
+	xt.Eq(t, "v1.2.3", haveTag)
+	xt.Eq(t, 42, haveBuild)
+
+	t.Run("flags are handled before pre-targets", func(t *testing.T) {
+		var ran []string
+		record := func(target *Target) error {
+			ran = append(ran, target.Name)
+			return nil
+		}
+		target := &Target{
+			Name:            "release",
+			PreTargets:      []*Target{{Name: "pre", Do: record}},
+			DeferredTargets: []*Target{{Name: "deferred", Do: record}},
+			HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+				return nil, errors.New("flag -tag is required")
+			},
+			Do: record,
+		}
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.StdErr = &strings.Builder{}
+		m.registerTargets(target)
+
+		xt.Eq(t, 1, m.make("release"))
+		xt.Eq(t, 0, len(ran))
+		xt.Eq(t, "flag -tag is required", m.Report().Targets[0].Error)
+	})
+
+	t.Run("hyphenated target names", func(t *testing.T) {
+		var have any
+		target := &Target{
+			Name:       "show-version",
+			PreTargets: []*Target{NewGoVersionTarget()},
+			Flags:      map[string]any{"version": `{{ index .Outputs "go-version" "version" }}`},
+			DefineFlags: func(flagSet *flag.FlagSet) {
+				flagSet.String("version", "", "Go version")
+			},
+			HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+				flagSet, err := target.ParseFlags()
+				if err != nil {
+					return nil, err
+				}
+				target.StoreFlag(flagSet, "version", "version")
+				return flagSet, nil
+			},
+			Do: func(target *Target) error {
+				have = target.Flags["version"]
+				return nil
+			},
+		}
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.registerTargets(target)
+
+		xt.Eq(t, 0, m.make("show-version"))
+		exp, err := Output[string](m, "go-version", "version")
+		xt.OK(t, err)
+		xt.Assert(t, strings.HasPrefix(exp, "go version go"), exp)
+		xt.Eq(t, exp, have)
+	})
+
+	t.Run("outside a run", func(t *testing.T) {
+		target := NewTarget("version", targetVersion.Do)
+		xt.OK(t, target.Do(target))
+
+		v, ok := target.Output("version")
+		xt.Assert(t, ok)
+		xt.Eq(t, "1.2.3", v)
+
+		v, ok = targetVersion.Output("build")
+		xt.Assert(t, ok)
+		xt.Eq(t, 42, v)
+	})
+
+	t.Run("typed access", func(t *testing.T) {
+		v, err := Output[string](m, "version", "version")
+		xt.OK(t, err)
+		xt.Eq(t, "1.2.3", v)
+
+		_, err = Output[string](m, "version", "build")
+		xt.KO(t, err)
+		xt.Eq(t, "output build of target version is int, not string", err.Error())
+
+		_, err = Output[string](m, "version", "commit")
+		xt.KO(t, err)
+		xt.Eq(t, "output commit of target version not available", err.Error())
+	})
+
+	t.Run("recorded in report", func(t *testing.T) {
+		data, err := os.ReadFile(reportFile)
+		xt.OK(t, err)
+
+		var report Report
+		xt.OK(t, json.Unmarshal(data, &report))
+		xt.Eq(t, "version", report.Targets[1].Name)
+		xt.Eq(t, map[string]any{"version": "1.2.3", "build": float64(42), "token": SecretMask},
+			report.Targets[1].Outputs)
+	})
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// instancesMu guards the instances of all pattern targets.
+var instancesMu sync.Mutex
+
+// isPattern returns whether name is the name of a pattern target, which is
+// a name containing `%` once.
+func isPattern(name string) bool {
+	return strings.Count(name, "%") == 1
+}
+
+// matchStem returns the part of name matched by `%` in pattern. It returns
+// false when name does not match, or when the stem would be empty.
+func matchStem(pattern, name string) (string, bool) {
+	prefix, suffix, _ := strings.Cut(pattern, "%")
+	if len(name) <= len(prefix)+len(suffix) ||
+		!strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	return name[len(prefix) : len(name)-len(suffix)], true
+}
+
+// Instance returns the target of pattern target t for stem. Its name is the
+// name of t with `%` replaced by stem, and Stem is set. The same target is
+// returned for the same stem, so it is executed only once per run even when
+// used as pre-target by several targets.
+//
+// Instance panics when t is not a pattern target.
+func (t *Target) Instance(stem string) *Target {
+	if !isPattern(t.Name) {
+		panic(fmt.Sprintf("gomake: target %s is not a pattern target", t.Name))
+	}
+
+	instancesMu.Lock()
+	defer instancesMu.Unlock()
+
+	if target, ok := t.instances[stem]; ok {
+		return target
+	}
+
+	target := t.Clone()
+	target.Name = strings.Replace(t.Name, "%", stem, 1)
+	target.Stem = stem
+	target.pattern = t.Name
+
+	if t.instances == nil {
+		t.instances = map[string]*Target{}
+	}
+	t.instances[stem] = target
+
+	return target
+}
+
+// matchPattern returns the instance of the pattern target in targets which
+// matches name. When several match, the one with the shortest stem is used.
+func matchPattern(targets map[string]*Target, name string) (*Target, bool) {
+	var match *Target
+	var matchStemLen int
+
+	for _, pattern := range sortedKeys(targets) {
+		if !isPattern(pattern) {
+			continue
+		}
+		stem, ok := matchStem(pattern, name)
+		if !ok || (match != nil && len(stem) >= matchStemLen) {
+			continue
+		}
+		match = targets[pattern].Instance(stem)
+		matchStemLen = len(stem)
+	}
+
+	return match, match != nil
+}
+
+// lookupTarget returns the registered target called name or, when there is
+// none, the instance of the matching pattern target.
+func (m *Maker) lookupTarget(name string) (*Target, bool) {
+	if target, ok := m.targetRegistry[name]; ok {
+		return target, true
+	}
+	return matchPattern(m.targetRegistry, name)
+}
+
+// NewMatrixTarget returns a target called name executing, one after the
+// other, a sub-target for each of sets. Each set holds the values of the
+// parameters named by keys, and sub-targets are named after name and these
+// values. For example, with keys GOOS and GOARCH and set {"linux", "amd64"},
+// the sub-target is called name-linux-amd64.
+//
+// Sub-targets execute do, and are customized using opts. Their parameters are
+// available as Params, and are set in the environment of their commands.
+// Registering the returned target also registers its sub-targets, so each can
+// be executed on its own.
+//
+// NewMatrixTarget panics when a set does not hold a value for each key, or
+// when sets result in the same sub-target name.
+func NewMatrixTarget(name string, keys []string, sets [][]string, do func(target *Target) error, opts ...TargetOption) *Target {
+	base := NewTarget(name, do, opts...)
+
+	seen := map[string]bool{}
+	subs := make([]*Target, len(sets))
+	for i, values := range sets {
+		if len(values) != len(keys) {
+			panic(fmt.Sprintf("gomake: target %s: set %v must have %d values", name, values, len(keys)))
+		}
+
+		sub := base.Clone()
+		sub.Name = name + "-" + strings.Join(values, "-")
+		if seen[sub.Name] {
+			panic(fmt.Sprintf("gomake: target %s: sub-target %s defined more than once", name, sub.Name))
+		}
+		seen[sub.Name] = true
+
+		sub.Params = map[string]string{}
+		if sub.Env.Set == nil {
+			sub.Env.Set = map[string]string{}
+		}
+		pairs := make([]string, len(keys))
+		for j, key := range keys {
+			sub.Params[key] = values[j]
+			sub.Env.Set[key] = values[j]
+			pairs[j] = key + "=" + values[j]
+		}
+
+		desc := "(" + strings.Join(pairs, ", ") + ")"
+		if base.Description != "" {
+			desc = base.Description + " " + desc
+		}
+		sub.Description = desc
+		subs[i] = sub
+	}
+
+	group := Seq(subs...)
+	group.Name = name
+	group.Description = base.Description
+	group.registerChildren = true
+
+	return group
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestPatternTargets(t *testing.T) {
+	newMaker := func(targets ...*Target) (*Maker, *strings.Builder) {
+		m := NewMaker()
+		stdOut := &strings.Builder{}
+		m.StdOut = stdOut
+		m.StdErr = &strings.Builder{}
+		m.LogDir = ""
+		m.registerTargets(targets...)
+		return m, stdOut
+	}
+
+	t.Run("match stem", func(t *testing.T) {
+		cases := []struct {
+			pattern string
+			name    string
+			stem    string
+			ok      bool
+		}{
+			{pattern: "build-%", name: "build-api", stem: "api", ok: true},
+			{pattern: "%.tar.gz", name: "dist.tar.gz", stem: "dist", ok: true},
+			{pattern: "test-%-race", name: "test-api-race", stem: "api", ok: true},
+			{pattern: "build-%", name: "build-", ok: false},
+			{pattern: "build-%", name: "test-api", ok: false},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				stem, ok := matchStem(c.pattern, c.name)
+				xt.Eq(t, c.ok, ok)
+				xt.Eq(t, c.stem, stem)
+			})
+		}
+	})
+
+	t.Run("do receives stem", func(t *testing.T) {
+		var got []string
+		build := NewTarget("build-%", func(target *Target) error {
+			got = append(got, target.Name+":"+target.Stem)
+			return nil
+		})
+
+		m, _ := newMaker(build)
+		xt.Eq(t, 0, m.make("build-api"))
+		xt.Eq(t, 0, m.make("build-worker"))
+		xt.Eq(t, []string{"build-api:api", "build-worker:worker"}, got)
+		xt.Eq(t, "build-worker", m.Report().Targets[0].Name)
+	})
+
+	t.Run("shortest stem wins", func(t *testing.T) {
+		var got string
+		record := func(target *Target) error {
+			got = target.pattern
+			return nil
+		}
+
+		m, _ := newMaker(NewTarget("build-%", record), NewTarget("build-api-%", record))
+		xt.Eq(t, 0, m.make("build-api-linux"))
+		xt.Eq(t, "build-api-%", got)
+	})
+
+	t.Run("exact name wins", func(t *testing.T) {
+		var got string
+		m, _ := newMaker(
+			NewTarget("build-%", func(target *Target) error { got = "pattern"; return nil }),
+			NewTarget("build-all", func(target *Target) error { got = "exact"; return nil }),
+		)
+		xt.Eq(t, 0, m.make("build-all"))
+		xt.Eq(t, "exact", got)
+	})
+
+	t.Run("instances run once", func(t *testing.T) {
+		count := 0
+		build := NewTarget("build-%", func(target *Target) error {
+			count++
+			return nil
+		})
+		xt.Assert(t, build.Instance("api") == build.Instance("api"))
+
+		deploy := NewTarget("deploy", func(*Target) error { return nil },
+			WithPreTargets(build.Instance("api")))
+
+		m, _ := newMaker(build, Seq(build.Instance("api"), deploy))
+		xt.Eq(t, 0, m.make("seq(build-api,deploy)"))
+		xt.Eq(t, 1, count)
+	})
+
+	t.Run("configured like pattern", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := filepath.Join(dir, "gomake.toml")
+		xt.OK(t, os.WriteFile(cfg, []byte(`
+[targets."build-%".flags]
+race = true
+[targets."build-api".flags]
+tags = "api"
+`), 0o600))
+
+		var got []string
+		build := NewTarget("build-%", func(target *Target) error {
+			flagSet := target.FlagSet()
+			xt.OK(t, flagSet.Parse(target.FlagArgs))
+			got = append(got, target.Name+" race="+flagSet.Lookup("race").Value.String()+
+				" tags="+flagSet.Lookup("tags").Value.String())
+			return nil
+		})
+		build.DefineFlags = func(flagSet *flag.FlagSet) {
+			flagSet.Bool("race", false, "")
+			flagSet.String("tags", "", "")
+		}
+
+		m, _ := newMaker(build)
+		m.ConfigFile = cfg
+		xt.Eq(t, 0, m.make("build-api"))
+		xt.Eq(t, 0, m.make("build-worker"))
+		xt.Eq(t, []string{"build-api race=true tags=api", "build-worker race=true tags="}, got)
+	})
+
+	t.Run("help", func(t *testing.T) {
+		m, stdOut := newMaker(NewTarget("build-%", nil, WithDescription("Builds cmd/<stem>")))
+		xt.Eq(t, 0, m.make("help", "build-api"))
+		xt.Eq(t, "Usage: build-api [flags]\n   Builds cmd/<stem>\n", stdOut.String())
+	})
+
+	t.Run("instance panics for non-pattern targets", func(t *testing.T) {
+		defer func() {
+			xt.Eq(t, "gomake: target build is not a pattern target", recover())
+		}()
+		NewTarget("build", nil).Instance("api")
+	})
+}
+
+func TestNewMatrixTarget(t *testing.T) {
+	var got []string
+	matrix := NewMatrixTarget("build", []string{"GOOS", "GOARCH"},
+		[][]string{{"linux", "amd64"}, {"darwin", "arm64"}},
+		func(target *Target) error {
+			env := map[string]string{}
+			for _, kv := range target.Environ() {
+				k, v, _ := strings.Cut(kv, "=")
+				env[k] = v
+			}
+			got = append(got, target.Name+" "+target.Params["GOOS"]+"/"+env["GOARCH"])
+			return nil
+		},
+		WithDescription("Builds binaries"))
+
+	newMaker := func() (*Maker, *strings.Builder) {
+		m := NewMaker()
+		stdOut := &strings.Builder{}
+		m.StdOut = stdOut
+		m.StdErr = &strings.Builder{}
+		m.LogDir = ""
+		m.registerTargets(matrix)
+		return m, stdOut
+	}
+
+	t.Run("sub-targets visible in help", func(t *testing.T) {
+		m, stdOut := newMaker()
+		xt.Eq(t, 0, m.make())
+		xt.Eq(t, `Available targets:
+   build
+      Builds binaries
+   build-darwin-arm64
+      Builds binaries (GOOS=darwin, GOARCH=arm64)
+   build-linux-amd64
+      Builds binaries (GOOS=linux, GOARCH=amd64)
+
+Built-in commands:
+`+helpBuiltins(m), stdOut.String())
+	})
+
+	t.Run("run group", func(t *testing.T) {
+		got = nil
+		m, _ := newMaker()
+		xt.Eq(t, 0, m.make("build"))
+		xt.Eq(t, []string{"build-linux-amd64 linux/amd64", "build-darwin-arm64 darwin/arm64"}, got)
+	})
+
+	t.Run("run sub-target", func(t *testing.T) {
+		got = nil
+		m, _ := newMaker()
+		xt.Eq(t, 0, m.make("build-darwin-arm64"))
+		xt.Eq(t, []string{"build-darwin-arm64 darwin/arm64"}, got)
+	})
+
+	t.Run("panics on missing values", func(t *testing.T) {
+		defer func() {
+			xt.Eq(t, "gomake: target build: set [linux] must have 2 values", recover())
+		}()
+		NewMatrixTarget("build", []string{"GOOS", "GOARCH"}, [][]string{{"linux"}}, nil)
+	})
+}
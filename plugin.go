@@ -0,0 +1,163 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// PluginPrefix is the prefix of the names of executables discovered as
+// plugins; `gomake-deploy` is executed as target deploy.
+const PluginPrefix = "gomake-"
+
+// PluginDescribeFlag is the only argument given to plugins when their
+// description is needed for help. Plugins answer by writing, to the standard
+// output, either the description as plain text or a JSON object such as
+// `{"description": "Deploys the application"}`.
+const PluginDescribeFlag = "--gomake-describe"
+
+// DefaultPluginDir is the directory, relative to the configuration file or
+// the working directory, searched for plugins when no plugin directories are
+// given.
+const DefaultPluginDir = ".gomake/plugins"
+
+// pluginDescribeTimeout is how long a plugin gets to describe itself.
+const pluginDescribeTimeout = 5 * time.Second
+
+// pluginDescription is the answer of a plugin to PluginDescribeFlag.
+type pluginDescription struct {
+	Description string `json:"description"`
+}
+
+// discoverPlugins registers a target for each plugin found in the plugin
+// directories and on PATH, unless a target with the same name is registered.
+// When the same plugin is found more than once, the first is used.
+func (m *Maker) discoverPlugins() {
+	if !m.Plugins || m.pluginsFound {
+		return
+	}
+	m.pluginsFound = true
+
+	dirs := m.PluginDirs
+	if len(dirs) == 0 {
+		base := "."
+		if m.ConfigFile != "" {
+			base = filepath.Dir(m.ConfigFile)
+		}
+		dirs = []string{filepath.Join(base, DefaultPluginDir)}
+	}
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			name, ok := pluginName(entry)
+			if !ok {
+				continue
+			}
+			if _, ok := m.targetRegistry[name]; ok {
+				continue
+			}
+			m.registerTargets(newPluginTarget(name, filepath.Join(dir, entry.Name())))
+		}
+	}
+}
+
+// pluginName returns the name of the target for entry, and whether entry is
+// an executable plugin.
+func pluginName(entry os.DirEntry) (string, bool) {
+	fileName := entry.Name()
+	if !strings.HasPrefix(fileName, PluginPrefix) || entry.IsDir() {
+		return "", false
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		return "", false
+	}
+
+	if runtime.GOOS == "windows" {
+		if !strings.EqualFold(filepath.Ext(fileName), ".exe") {
+			return "", false
+		}
+		fileName = fileName[:len(fileName)-len(".exe")]
+	} else if info.Mode()&0o111 == 0 {
+		return "", false
+	}
+
+	name := strings.TrimPrefix(fileName, PluginPrefix)
+	return name, name != ""
+}
+
+// newPluginTarget returns the target executing the plugin at path. Flag
+// arguments are passed to the plugin as they are given on the command line.
+// The plugin gets the name of the target and the ID of the run using the
+// environment variables GOMAKE_TARGET and GOMAKE_RUN_ID.
+func newPluginTarget(name, path string) *Target {
+	return &Target{
+		Name:   name,
+		plugin: path,
+		Do: func(target *Target) error {
+			cmd := exec.Command(target.plugin, target.FlagArgs...)
+			cmd.Stdout = target.Maker.StdOut
+			cmd.Stderr = target.Maker.StdErr
+			cmd.Env = []string{
+				FlagEnvPrefix + "_TARGET=" + target.Name,
+				FlagEnvPrefix + "_RUN_ID=" + target.Maker.report.RunID,
+			}
+			return target.RunCmd(cmd)
+		},
+	}
+}
+
+// describePlugins sets the description of registered plugin targets which
+// have none, asking the plugins using PluginDescribeFlag.
+func (m *Maker) describePlugins() {
+	for _, name := range sortedKeys(m.targetRegistry) {
+		target := m.targetRegistry[name]
+		if target.plugin == "" || target.Description != "" {
+			continue
+		}
+
+		desc, err := describePlugin(target.plugin)
+		if err != nil {
+			m.Log.Debugf("describing plugin %s: %s", target.plugin, err)
+			continue
+		}
+		target.Description = desc
+	}
+}
+
+func describePlugin(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginDescribeTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, PluginDescribeFlag).Output()
+	if err != nil {
+		return "", err
+	}
+
+	out = bytes.TrimSpace(out)
+	if bytes.HasPrefix(out, []byte("{")) {
+		var pd pluginDescription
+		if err := json.Unmarshal(out, &pd); err != nil {
+			return "", err
+		}
+		return pd.Description, nil
+	}
+
+	desc, _, _ := strings.Cut(string(out), "\n")
+	return strings.TrimSpace(desc), nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestMaker_plugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+
+	writePlugin := func(t *testing.T, dir, name, script string) {
+		t.Helper()
+		xt.OK(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755))
+	}
+
+	projectDir := t.TempDir()
+	pathDir := t.TempDir()
+	t.Setenv("PATH", pathDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	writePlugin(t, projectDir, "gomake-deploy", `
+if [ "$1" = "--gomake-describe" ]; then echo '{"description": "Deploys"}'; exit 0; fi
+echo "deploy $GOMAKE_TARGET $*"
+`)
+	writePlugin(t, pathDir, "gomake-deploy", `echo "from PATH"`)
+	writePlugin(t, pathDir, "gomake-lint", `
+if [ "$1" = "--gomake-describe" ]; then printf "Lints code\nmore\n"; exit 0; fi
+exit 3
+`)
+	writePlugin(t, pathDir, "gomake-build", `echo "plugin build"`)
+	xt.OK(t, os.WriteFile(filepath.Join(pathDir, "gomake-notes"), []byte("not executable"), 0o644))
+
+	newMaker := func() (*Maker, *strings.Builder) {
+		m := NewMaker()
+		stdOut := &strings.Builder{}
+		m.StdOut = stdOut
+		m.StdErr = &strings.Builder{}
+		m.LogDir = ""
+		m.Plugins = true
+		m.PluginDirs = []string{projectDir}
+		m.registerTargets(NewTarget("build", func(target *Target) error {
+			_, _ = target.Maker.StdOut.Write([]byte("go build\n"))
+			return nil
+		}))
+		return m, stdOut
+	}
+
+	t.Run("help", func(t *testing.T) {
+		m, stdOut := newMaker()
+		xt.Eq(t, 0, m.make())
+
+		out := stdOut.String()
+		xt.Assert(t, strings.Contains(out, "   deploy\n      Deploys\n"), out)
+		xt.Assert(t, strings.Contains(out, "   lint\n      Lints code\n"), out)
+		xt.Assert(t, !strings.Contains(out, "notes"), out)
+	})
+
+	t.Run("flags are passed through", func(t *testing.T) {
+		m, stdOut := newMaker()
+		xt.Eq(t, 0, m.make("deploy", "-env", "prod", "extra"))
+		xt.Eq(t, "deploy deploy -env prod extra\n", stdOut.String())
+
+		report := m.Report()
+		xt.Eq(t, StatusSucceeded, report.Targets[0].Status)
+		xt.Eq(t, 1, len(report.Targets[0].Commands))
+	})
+
+	t.Run("profile is passed through", func(t *testing.T) {
+		m, stdOut := newMaker()
+		xt.Eq(t, 0, m.make("deploy", "-profile", "prod"))
+		xt.Eq(t, "deploy deploy -profile prod\n", stdOut.String())
+		xt.Eq(t, "", m.Profile)
+	})
+
+	t.Run("failing plugin", func(t *testing.T) {
+		m, _ := newMaker()
+		xt.Eq(t, 1, m.make("lint"))
+		xt.Eq(t, 3, m.Report().Targets[0].Commands[0].ExitCode)
+	})
+
+	t.Run("registered targets take precedence", func(t *testing.T) {
+		m, stdOut := newMaker()
+		xt.Eq(t, 0, m.make("build"))
+		xt.Eq(t, "go build\n", stdOut.String())
+	})
+
+	t.Run("defined targets take precedence", func(t *testing.T) {
+		m, stdOut := newMaker()
+		m.Log.Level = LevelWarn
+		m.ConfigFile = filepath.Join(t.TempDir(), "gomake.yaml")
+		xt.OK(t, os.WriteFile(m.ConfigFile, []byte("targets:\n  deploy:\n    run: echo defined\n"), 0o600))
+
+		xt.Eq(t, 0, m.make("deploy"))
+		xt.Eq(t, "defined\n", stdOut.String())
+
+		m.undefineTargets()
+		xt.Eq(t, projectDir, filepath.Dir(m.targetRegistry["deploy"].plugin))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		m, _ := newMaker()
+		m.Plugins = false
+		xt.Eq(t, 1, m.make("deploy"))
+	})
+}
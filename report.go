@@ -0,0 +1,257 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TargetStatus is the outcome of a target within a run.
+type TargetStatus string
+
+const (
+	StatusSucceeded TargetStatus = "succeeded"
+	StatusFailed    TargetStatus = "failed"
+	StatusSkipped   TargetStatus = "skipped"
+)
+
+// Report describes what happened during a run of the Maker. Durations are
+// stored, and serialized as JSON, in nanoseconds.
+type Report struct {
+	RunID    string          `json:"runID"`
+	Started  time.Time       `json:"started"`
+	Duration time.Duration   `json:"duration"`
+	ExitCode int             `json:"exitCode"`
+	Targets  []*TargetReport `json:"targets"`
+}
+
+// TargetReport describes the execution of a single target.
+type TargetReport struct {
+	Name     string           `json:"name"`
+	Status   TargetStatus     `json:"status"`
+	Started  time.Time        `json:"started"`
+	Duration time.Duration    `json:"duration"`
+	Attempts int              `json:"attempts"`
+	Commands []*CommandReport `json:"commands,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	LogFile  string           `json:"logFile,omitempty"`
+	// Outputs holds the values published by the target using SetOutput.
+	Outputs map[string]any `json:"outputs,omitempty"`
+
+	// lane is the thread ID of the target in the trace of the run
+	lane int
+
+	ResourceUsage
+}
+
+// CommandReport describes a command executed by a target.
+type CommandReport struct {
+	Args     []string      `json:"args"`
+	Dir      string        `json:"dir,omitempty"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	ExitCode int           `json:"exitCode"`
+	Error    string        `json:"error,omitempty"`
+
+	ResourceUsage
+}
+
+// ResourceUsage holds the resources used by commands. For a target, CPU times
+// are summed and MaxRSS is the maximum of all its commands. MaxRSS is only
+// available on Linux.
+type ResourceUsage struct {
+	UserCPU   time.Duration `json:"userCPU"`
+	SystemCPU time.Duration `json:"systemCPU"`
+	MaxRSS    int64         `json:"maxRSS"`
+}
+
+func (ru *ResourceUsage) add(other ResourceUsage) {
+	ru.UserCPU += other.UserCPU
+	ru.SystemCPU += other.SystemCPU
+	if other.MaxRSS > ru.MaxRSS {
+		ru.MaxRSS = other.MaxRSS
+	}
+}
+
+func (ru *ResourceUsage) String() string {
+	return fmt.Sprintf("user %s, system %s, max RSS %s",
+		roundDuration(ru.UserCPU), roundDuration(ru.SystemCPU), formatBytes(ru.MaxRSS))
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func newReport() *Report {
+	started := time.Now()
+	return &Report{
+		RunID:   fmt.Sprintf("%s-%d", started.Format("20060102-150405.000"), os.Getpid()),
+		Started: started,
+	}
+}
+
+func (r *Report) addTarget(name string) *TargetReport {
+	tr := &TargetReport{
+		Name:     name,
+		Started:  time.Now(),
+		Attempts: 1,
+	}
+	r.Targets = append(r.Targets, tr)
+	return tr
+}
+
+// finish records the status and duration of the target, together with the
+// error explaining why it failed or was skipped.
+func (tr *TargetReport) finish(status TargetStatus, err error) {
+	tr.Duration = time.Since(tr.Started)
+	tr.Status = status
+	if err != nil {
+		tr.Error = err.Error()
+	}
+}
+
+// Failed returns the reports of targets which failed.
+func (r *Report) Failed() []*TargetReport {
+	var res []*TargetReport
+	for _, tr := range r.Targets {
+		if tr.Status == StatusFailed {
+			res = append(res, tr)
+		}
+	}
+	return res
+}
+
+// WriteFile writes the report to the file at path. The format is derived from
+// the extension: files ending with `.xml` get JUnit XML, all others JSON.
+func (r *Report) WriteFile(path string) error {
+	var data []byte
+	var err error
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml":
+		data, err = r.JUnit()
+	default:
+		data, err = r.JSON()
+	}
+	if err != nil {
+		return fmt.Errorf("creating report %s (%w)", path, err)
+	}
+
+	if err := os.WriteFile(path, data, 0o640); err != nil {
+		return fmt.Errorf("writing report (%w)", err)
+	}
+
+	return nil
+}
+
+// JSON returns the report as indented JSON.
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit returns the report as JUnit XML. Each target is reported as a test case.
+func (r *Report) JUnit() ([]byte, error) {
+	suite := junitTestSuite{
+		Name:      "gomake",
+		Time:      junitSeconds(r.Duration),
+		Timestamp: r.Started.Format("2006-01-02T15:04:05"),
+	}
+
+	for _, tr := range r.Targets {
+		tc := junitTestCase{
+			Name:      tr.Name,
+			ClassName: "gomake",
+			Time:      junitSeconds(tr.Duration),
+		}
+
+		var commands []string
+		for _, c := range tr.Commands {
+			commands = append(commands, fmt.Sprintf("%s (exit code %d; %s)",
+				strings.Join(c.Args, " "), c.ExitCode, c.ResourceUsage.String()))
+		}
+		tc.SystemOut = strings.Join(commands, "\n")
+
+		switch tr.Status {
+		case StatusFailed:
+			suite.Failures++
+			tc.Failure = &junitMessage{Message: tr.Error, Text: tr.Error}
+		case StatusSkipped:
+			suite.Skipped++
+			tc.Skipped = &junitMessage{Message: tr.Error}
+		case StatusSucceeded:
+		}
+
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	suites := junitTestSuites{
+		Name:     suite.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestReport(t *testing.T) {
+	targetOK := &Target{
+		Name: "ok",
+		Do: func(target *Target) error {
+			return target.RunCmd(exec.Command("go", "version"))
+		},
+	}
+
+	targetFail := &Target{
+		Name: "fail",
+		Do: func(target *Target) error {
+			return target.RunCmd(exec.Command("go", "no-such-command"))
+		},
+	}
+
+	targetSkipped := &Target{
+		Name:       "skipped",
+		PreTargets: []*Target{targetFail},
+		Do: func(target *Target) error {
+			return nil
+		},
+	}
+
+	t.Run("successful target", func(t *testing.T) {
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.registerTargets(targetOK)
+
+		xt.Eq(t, 0, m.make("ok"))
+
+		report := m.Report()
+		xt.Eq(t, 0, report.ExitCode)
+		xt.Eq(t, 1, len(report.Targets))
+
+		tr := report.Targets[0]
+		xt.Eq(t, "ok", tr.Name)
+		xt.Eq(t, StatusSucceeded, tr.Status)
+		xt.Eq(t, 1, tr.Attempts)
+		xt.Eq(t, 1, len(tr.Commands))
+		xt.Eq(t, []string{"go", "version"}, tr.Commands[0].Args)
+		xt.Eq(t, 0, tr.Commands[0].ExitCode)
+	})
+
+	t.Run("resource usage", func(t *testing.T) {
+		var buf strings.Builder
+		m := NewMaker()
+		m.StdOut = &buf
+		m.Verbose = true
+		m.registerTargets(targetOK)
+
+		xt.Eq(t, 0, m.make("ok"))
+
+		tr := m.Report().Targets[0]
+		xt.Eq(t, tr.Commands[0].ResourceUsage, tr.ResourceUsage)
+		if runtime.GOOS == "linux" {
+			xt.Assert(t, tr.MaxRSS > 0, "expected max RSS")
+		}
+		xt.Assert(t, strings.Contains(buf.String(), m.Log.Prefix+" resource usage: user "), buf.String())
+	})
+
+	t.Run("invalid flag is reported", func(t *testing.T) {
+		reportFile := filepath.Join(t.TempDir(), "report.json")
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.StdErr = &strings.Builder{}
+		m.Reports = []string{reportFile}
+		m.registerTargets(NewVendorTarget())
+
+		xt.Eq(t, 1, m.make("vendor", "-no-such-flag"))
+
+		data, err := os.ReadFile(reportFile)
+		xt.OK(t, err)
+		var report Report
+		xt.OK(t, json.Unmarshal(data, &report))
+		xt.Eq(t, 1, report.ExitCode)
+		xt.Eq(t, StatusFailed, report.Targets[0].Status)
+		xt.Eq(t, "flag provided but not defined: -no-such-flag", report.Targets[0].Error)
+	})
+
+	t.Run("failing pre-target skips target", func(t *testing.T) {
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.StdErr = &strings.Builder{}
+		m.registerTargets(targetSkipped)
+
+		xt.Eq(t, 1, m.make("skipped"))
+
+		report := m.Report()
+		xt.Eq(t, 1, report.ExitCode)
+		xt.Eq(t, 2, len(report.Targets))
+		xt.Eq(t, StatusSkipped, report.Targets[0].Status)
+		xt.Eq(t, StatusFailed, report.Targets[1].Status)
+		xt.Eq(t, 2, report.Targets[1].Commands[0].ExitCode)
+		xt.Eq(t, 1, len(report.Failed()))
+	})
+
+	t.Run("write JSON and JUnit XML", func(t *testing.T) {
+		dir := t.TempDir()
+		jsonFile := filepath.Join(dir, "report.json")
+		junitFile := filepath.Join(dir, "report.xml")
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.StdErr = &strings.Builder{}
+		m.Reports = []string{jsonFile, junitFile}
+		m.registerTargets(targetSkipped)
+
+		xt.Eq(t, 1, m.make("skipped"))
+
+		data, err := os.ReadFile(jsonFile)
+		xt.OK(t, err)
+		var report Report
+		xt.OK(t, json.Unmarshal(data, &report))
+		xt.Eq(t, 2, len(report.Targets))
+		xt.Eq(t, "fail", report.Targets[1].Name)
+
+		data, err = os.ReadFile(junitFile)
+		xt.OK(t, err)
+		junit := string(data)
+		xt.Assert(t, strings.Contains(junit, `<testsuites name="gomake" tests="2" failures="1" skipped="1"`), junit)
+		xt.Assert(t, strings.Contains(junit, `<testcase name="fail" classname="gomake"`), junit)
+		xt.Assert(t, strings.Contains(junit, fmt.Sprintf("<skipped message=%q>",
+			"pre-target of skipped failed")), junit)
+	})
+}
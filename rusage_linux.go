@@ -0,0 +1,17 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"os"
+	"syscall"
+)
+
+// maxRSS returns the maximum resident set size in bytes of the process which
+// finished with state.
+func maxRSS(state *os.ProcessState) int64 {
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return ru.Maxrss * 1024 // Linux reports kilobytes
+	}
+	return 0
+}
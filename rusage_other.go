@@ -0,0 +1,12 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+//go:build !linux
+
+package gomake
+
+import "os"
+
+// maxRSS is not supported on this platform and always returns 0.
+func maxRSS(_ *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,301 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SecretMask replaces secrets in output.
+const SecretMask = "***"
+
+// secretMinLength is the minimum length of secret values; shorter values would
+// mask too much output, and are ignored.
+const secretMinLength = 4
+
+// maxPendingLine is the maximum length of an incomplete line held back when
+// patterns are registered.
+const maxPendingLine = 4096
+
+// maskFlushDelay is how long output is held back by MaskWriter when nothing
+// else is written, so that for example prompts without line ending show.
+const maskFlushDelay = 100 * time.Millisecond
+
+// Secrets is a registry of values which are masked in the output of the
+// Maker, its log files and run reports.
+type Secrets struct {
+	mu       sync.RWMutex
+	values   map[string]bool
+	envNames []string
+	flags    map[string]bool
+	patterns []*regexp.Regexp
+	getenv   func(string) string
+}
+
+func newSecrets() *Secrets {
+	return &Secrets{
+		values: map[string]bool{},
+		flags:  map[string]bool{},
+		getenv: os.Getenv,
+	}
+}
+
+// Add marks values as secret.
+func (s *Secrets) Add(values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range values {
+		if len(v) >= secretMinLength {
+			s.values[v] = true
+		}
+	}
+}
+
+// AddEnv marks the values of the environment variables names as secret. The
+// environment is consulted each time output is masked.
+func (s *Secrets) AddEnv(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.envNames = append(s.envNames, names...)
+}
+
+// AddFlag marks the values of the target flags names as secret. The values
+// are registered when a target with such flag has handled its flags.
+func (s *Secrets) AddFlag(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range names {
+		s.flags[name] = true
+	}
+}
+
+// AddPattern marks everything matching the regular expression pattern as
+// secret. Patterns are matched line by line.
+func (s *Secrets) AddPattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.patterns = append(s.patterns, re)
+	return nil
+}
+
+// addFlagValues registers the values of secret flags found in flagSet and in
+// the flags of target.
+func (s *Secrets) addFlagValues(target *Target, flagSet *flag.FlagSet) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.flags))
+	for name := range s.flags {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+
+	for _, name := range names {
+		if flagSet != nil {
+			if f := flagSet.Lookup(name); f != nil {
+				s.Add(f.Value.String())
+			}
+		}
+		if v, ok := target.Flags[name].(string); ok {
+			s.Add(v)
+		}
+	}
+}
+
+// secretValues returns the secret values, longest first so that a secret
+// containing another is masked as a whole.
+func (s *Secrets) secretValues() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]string, 0, len(s.values)+len(s.envNames))
+	for v := range s.values {
+		res = append(res, v)
+	}
+	for _, name := range s.envNames {
+		if v := s.getenv(name); len(v) >= secretMinLength {
+			res = append(res, v)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return len(res[i]) > len(res[j])
+	})
+
+	return res
+}
+
+func (s *Secrets) hasPatterns() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.patterns) > 0
+}
+
+// Mask returns str with all secrets replaced by SecretMask.
+func (s *Secrets) Mask(str string) string {
+	return string(s.mask([]byte(str), s.secretValues()))
+}
+
+func (s *Secrets) mask(p []byte, values []string) []byte {
+	for _, v := range values {
+		p = bytes.ReplaceAll(p, []byte(v), []byte(SecretMask))
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, re := range s.patterns {
+		p = re.ReplaceAll(p, []byte(SecretMask))
+	}
+
+	return p
+}
+
+// Writer returns a writer masking secrets before passing the output to w.
+// Output which might be the start of a secret is held back until the next
+// write or until the writer is flushed, which happens at the latest when
+// nothing was written for a short while.
+func (s *Secrets) Writer(w io.Writer) *MaskWriter {
+	return &MaskWriter{w: w, secrets: s}
+}
+
+// MaskWriter masks secrets in everything written to it.
+type MaskWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	secrets *Secrets
+	pending []byte
+	timer   *time.Timer
+}
+
+func (mw *MaskWriter) Write(p []byte) (int, error) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	buf := append(mw.pending, p...)
+
+	cut := len(buf)
+	if mw.secrets.hasPatterns() {
+		// patterns are matched on complete lines
+		i := bytes.LastIndexByte(buf, '\n')
+		if len(buf)-(i+1) <= maxPendingLine {
+			cut = i + 1
+		}
+	}
+
+	values := mw.secrets.secretValues()
+	masked := mw.secrets.mask(buf[:cut], values)
+	hold := heldBack(masked, values)
+
+	mw.pending = append(append([]byte{}, masked[len(masked)-hold:]...), buf[cut:]...)
+
+	if len(mw.pending) > 0 {
+		if mw.timer == nil {
+			mw.timer = time.AfterFunc(maskFlushDelay, func() { _ = mw.Flush() })
+		} else {
+			mw.timer.Reset(maskFlushDelay)
+		}
+	}
+
+	if len(masked) > hold {
+		if _, err := mw.w.Write(masked[:len(masked)-hold]); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (mw *MaskWriter) unwrap() io.Writer {
+	return mw.w
+}
+
+// Flush writes output which was held back.
+func (mw *MaskWriter) Flush() error {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	if mw.timer != nil {
+		mw.timer.Stop()
+	}
+	if len(mw.pending) == 0 {
+		return nil
+	}
+
+	pending := mw.pending
+	mw.pending = nil
+
+	_, err := mw.w.Write(mw.secrets.mask(pending, mw.secrets.secretValues()))
+	return err
+}
+
+// heldBack returns the length of the longest suffix of buf which is the start
+// of one of the values.
+func heldBack(buf []byte, values []string) int {
+	hold := 0
+	for _, v := range values {
+		n := len(v) - 1
+		if n > len(buf) {
+			n = len(buf)
+		}
+		for ; n > hold; n-- {
+			if bytes.HasSuffix(buf, []byte(v[:n])) {
+				hold = n
+				break
+			}
+		}
+	}
+	return hold
+}
+
+// maskReport returns a copy of r in which secrets are masked.
+func (s *Secrets) maskReport(r *Report) *Report {
+	values := s.secretValues()
+	mask := func(str string) string {
+		return string(s.mask([]byte(str), values))
+	}
+
+	masked := *r
+	masked.Targets = make([]*TargetReport, len(r.Targets))
+	for i, tr := range r.Targets {
+		mtr := *tr
+		mtr.Error = mask(tr.Error)
+		if tr.Outputs != nil {
+			mtr.Outputs = make(map[string]any, len(tr.Outputs))
+			for k, v := range tr.Outputs {
+				if str, ok := v.(string); ok {
+					v = mask(str)
+				}
+				mtr.Outputs[k] = v
+			}
+		}
+		mtr.Commands = make([]*CommandReport, len(tr.Commands))
+		for j, cr := range tr.Commands {
+			mcr := *cr
+			mcr.Error = mask(cr.Error)
+			mcr.Args = make([]string, len(cr.Args))
+			for k, arg := range cr.Args {
+				mcr.Args[k] = mask(arg)
+			}
+			mtr.Commands[j] = &mcr
+		}
+		masked.Targets[i] = &mtr
+	}
+
+	return &masked
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golistic/xt"
+)
+
+// chanWriter sends everything written to it on the channel.
+type chanWriter chan string
+
+func (cw chanWriter) Write(p []byte) (int, error) {
+	cw <- string(p)
+	return len(p), nil
+}
+
+func TestSecrets(t *testing.T) {
+	t.Run("mask values", func(t *testing.T) {
+		s := newSecrets()
+		s.Add("s3cr3t", "abc")
+
+		xt.Eq(t, "token=*** abc", s.Mask("token=s3cr3t abc"))
+	})
+
+	t.Run("mask environment variables", func(t *testing.T) {
+		t.Setenv("GOMAKE_TEST_TOKEN", "tok-123456")
+		s := newSecrets()
+		s.AddEnv("GOMAKE_TEST_TOKEN")
+
+		xt.Eq(t, "using ***", s.Mask("using tok-123456"))
+	})
+
+	t.Run("mask patterns", func(t *testing.T) {
+		s := newSecrets()
+		xt.OK(t, s.AddPattern(`ghp_[A-Za-z0-9]+`))
+
+		xt.Eq(t, "token *** used", s.Mask("token ghp_Abc123 used"))
+		xt.KO(t, s.AddPattern(`(`))
+	})
+
+	t.Run("writer masks values split across writes", func(t *testing.T) {
+		var buf strings.Builder
+		s := newSecrets()
+		s.Add("s3cr3t")
+		w := s.Writer(&buf)
+
+		for _, chunk := range []string{"pass", "word: s3", "cr", "3t and s", "3", "c"} {
+			_, err := w.Write([]byte(chunk))
+			xt.OK(t, err)
+		}
+		xt.Eq(t, "password: *** and ", buf.String())
+
+		xt.OK(t, w.Flush())
+		xt.Eq(t, "password: *** and s3c", buf.String())
+	})
+
+	t.Run("writer masks patterns per line", func(t *testing.T) {
+		var buf strings.Builder
+		s := newSecrets()
+		xt.OK(t, s.AddPattern(`ghp_[A-Za-z0-9]+`))
+		w := s.Writer(&buf)
+
+		_, _ = w.Write([]byte("token ghp_Ab"))
+		_, _ = w.Write([]byte("c123\nnext"))
+		xt.Eq(t, "token ***\n", buf.String())
+		xt.OK(t, w.Flush())
+		xt.Eq(t, "token ***\nnext", buf.String())
+	})
+
+	t.Run("writer flushes when nothing is written", func(t *testing.T) {
+		written := make(chanWriter, 10)
+		s := newSecrets()
+		xt.OK(t, s.AddPattern(`ghp_[A-Za-z0-9]+`))
+		w := s.Writer(written)
+
+		_, err := w.Write([]byte("Password: "))
+		xt.OK(t, err)
+
+		select {
+		case have := <-written:
+			xt.Eq(t, "Password: ", have)
+		case <-time.After(10 * maskFlushDelay):
+			t.Fatal("prompt without line ending was not flushed")
+		}
+	})
+
+	t.Run("secret flags", func(t *testing.T) {
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		flagSet.String("password", "", "")
+		xt.OK(t, flagSet.Parse([]string{"-password", "from-cmdline"}))
+
+		s := newSecrets()
+		s.AddFlag("password", "token")
+		s.addFlagValues(&Target{Flags: map[string]any{"token": "from-flags"}}, flagSet)
+
+		xt.Eq(t, "*** ***", s.Mask("from-cmdline from-flags"))
+	})
+
+	t.Run("output, logs and reports of runs", func(t *testing.T) {
+		t.Setenv("GOMAKE_TEST_TOKEN", "tok-123456")
+
+		target := &Target{
+			Name: "leaky",
+			Do: func(target *Target) error {
+				cmd := exec.Command("go", "env", "GOPRIVATE")
+				cmd.Env = append(os.Environ(), "GOPRIVATE="+os.Getenv("GOMAKE_TEST_TOKEN"))
+				cmd.Stdout = target.Maker.StdOut
+				if err := target.RunCmd(cmd); err != nil {
+					return err
+				}
+				return fmt.Errorf("failed using %s", os.Getenv("GOMAKE_TEST_TOKEN"))
+			},
+		}
+
+		dir := t.TempDir()
+		reportFile := filepath.Join(dir, "report.json")
+
+		var bufOut, bufErr strings.Builder
+		var lines []string
+		m := NewMaker()
+		m.StdOut = &bufOut
+		m.StdErr = &bufErr
+		m.LogDir = dir
+		m.Reports = []string{reportFile}
+		m.Secrets.AddEnv("GOMAKE_TEST_TOKEN")
+		m.AddObserver(ObserverFunc(func(event Event) {
+			if event.Kind == EventOutput {
+				lines = append(lines, event.Line)
+			}
+		}))
+		m.registerTargets(target)
+
+		xt.Eq(t, 1, m.make("leaky"))
+
+		xt.Eq(t, "***\n", bufOut.String())
+		xt.Assert(t, !strings.Contains(bufErr.String(), "tok-123456"), bufErr.String())
+		xt.Eq(t, []string{"***"}, lines)
+
+		data, err := os.ReadFile(m.Report().Targets[0].LogFile)
+		xt.OK(t, err)
+		xt.Assert(t, !strings.Contains(string(data), "tok-123456"), string(data))
+
+		data, err = os.ReadFile(reportFile)
+		xt.OK(t, err)
+		xt.Assert(t, !strings.Contains(string(data), "tok-123456"), string(data))
+		xt.Assert(t, strings.Contains(string(data), "failed using ***"), string(data))
+	})
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultShimCommand is the command to which the targets of shims forward.
+const DefaultShimCommand = "go run ./cmd/make"
+
+// shimHeader is the first line of every shim, used to recognize them.
+const shimHeader = "# Code generated by gomake shims; DO NOT EDIT."
+
+var reJustRecipe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// shim is a file forwarding each target to the gomake program.
+type shim struct {
+	name   string
+	render func(command string, targets []*Target) []byte
+}
+
+var (
+	shimMakefile = shim{name: "Makefile", render: renderMakefileShim}
+	shimJustfile = shim{name: "justfile", render: renderJustfileShim}
+)
+
+// builtinShims writes, or with -check verifies, a Makefile and optionally a
+// justfile in which every target forwards to the gomake program.
+func (m *Maker) builtinShims(args []string) int {
+	flagSet := flag.NewFlagSet("shims", flag.ContinueOnError)
+	flagSet.SetOutput(m.StdErr)
+	check := flagSet.Bool("check", false, "Fail when shims are missing or out of date instead of writing them")
+	just := flagSet.Bool("just", false, "Also generate a justfile")
+	dir := flagSet.String("dir", ".", "Directory in which shims are written")
+	force := flagSet.Bool("force", false, "Overwrite files which were not generated by gomake")
+	command := flagSet.String("cmd", DefaultShimCommand, "Command to which targets forward")
+	if err := flagSet.Parse(args); err != nil {
+		return 1
+	}
+
+	if m.Plugins && *command == DefaultShimCommand {
+		*command += " -plugins"
+	}
+
+	m.describePlugins()
+	targets := m.shimTargets()
+
+	shims := []shim{shimMakefile}
+	if *just {
+		shims = append(shims, shimJustfile)
+	}
+
+	exitCode := 0
+	for _, s := range shims {
+		path := filepath.Join(*dir, s.name)
+		content := s.render(*command, targets)
+
+		current, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			m.Log.Error(err)
+			return 1
+		}
+
+		if *check {
+			if !bytes.Equal(current, content) {
+				m.Log.Errorf("%s is out of date; run shims to update it", path)
+				exitCode = 1
+			}
+			continue
+		}
+
+		if current != nil && !*force && !bytes.HasPrefix(current, []byte(shimHeader)) {
+			m.Log.Errorf("%s was not generated by gomake; use -force to overwrite", path)
+			return 1
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			m.Log.Error(err)
+			return 1
+		}
+		m.Log.Info("wrote", path)
+	}
+
+	return exitCode
+}
+
+// shimTargets returns the registered targets which can be forwarded to,
+// sorted by name. Pattern targets and targets named like the built-in help
+// are left out.
+func (m *Maker) shimTargets() []*Target {
+	var targets []*Target
+	for name, target := range m.targetRegistry {
+		if isPattern(name) || name == "help" {
+			continue
+		}
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Name < targets[j].Name
+	})
+	return targets
+}
+
+// shimComment returns description as comment lines.
+func shimComment(description string) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(description), "\n") {
+		sb.WriteString(strings.TrimSpace("# "+line) + "\n")
+	}
+	return sb.String()
+}
+
+// renderMakefileShim returns a Makefile with a rule for each target. Flags
+// are given to targets using the variable ARGS, and the command can be
+// replaced using the variable GOMAKE.
+func renderMakefileShim(command string, targets []*Target) []byte {
+	var sb strings.Builder
+	sb.WriteString(shimHeader + "\n")
+	sb.WriteString("# Targets forward to gomake; use ARGS to pass flags, for example:\n")
+	sb.WriteString("#   make build ARGS=\"-tag 1.0.0\"\n\n")
+	sb.WriteString("GOMAKE ?= " + command + "\n\n")
+
+	phony := []string{"help"}
+	for _, target := range targets {
+		phony = append(phony, makeRuleName(target.Name))
+	}
+	sb.WriteString(".PHONY: " + strings.Join(phony, " ") + "\n\n")
+
+	sb.WriteString("# Shows the available targets\nhelp:\n\t@$(GOMAKE) help\n")
+	for _, target := range targets {
+		sb.WriteString("\n")
+		if target.Description != "" {
+			sb.WriteString(shimComment(target.Description))
+		}
+		_, _ = fmt.Fprintf(&sb, "%s:\n\t@$(GOMAKE) %s $(ARGS)\n", makeRuleName(target.Name), target.Name)
+	}
+
+	return []byte(sb.String())
+}
+
+// makeRuleName escapes name for use as target of a Makefile rule.
+func makeRuleName(name string) string {
+	return strings.NewReplacer("$", "$$", ":", `\:`, "#", `\#`).Replace(name)
+}
+
+// renderJustfileShim returns a justfile with a recipe for each target.
+// Recipe names cannot contain the namespace separator, which is replaced
+// with a hyphen; targets which still cannot be named are left out.
+func renderJustfileShim(command string, targets []*Target) []byte {
+	var sb strings.Builder
+	sb.WriteString(shimHeader + "\n")
+	sb.WriteString("# Targets forward to gomake; flags follow the target, for example:\n")
+	sb.WriteString("#   just build -tag 1.0.0\n\n")
+	_, _ = fmt.Fprintf(&sb, "gomake := env_var_or_default(\"GOMAKE\", %q)\n\n", command)
+
+	sb.WriteString("# Shows the available targets\nhelp:\n    @{{gomake}} help\n")
+	seen := map[string]bool{"help": true}
+	for _, target := range targets {
+		recipe := strings.ReplaceAll(target.Name, NamespaceSeparator, "-")
+		if !reJustRecipe.MatchString(recipe) || seen[recipe] {
+			continue
+		}
+		seen[recipe] = true
+
+		sb.WriteString("\n")
+		if target.Description != "" {
+			sb.WriteString(shimComment(target.Description))
+		}
+		_, _ = fmt.Fprintf(&sb, "%s *args:\n    @{{gomake}} %s {{args}}\n", recipe, target.Name)
+	}
+
+	return []byte(sb.String())
+}
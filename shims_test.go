@@ -0,0 +1,125 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestMaker_shims(t *testing.T) {
+	newMaker := func() (*Maker, *strings.Builder) {
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		stdErr := &strings.Builder{}
+		m.StdErr = stdErr
+		m.LogDir = ""
+		m.registerTargets(
+			NewTarget("build", nil, WithDescription("Builds the application")),
+			NewTarget("gen-%", nil),
+		)
+		m.Mount("tools", NewTarget("lint", nil))
+		return m, stdErr
+	}
+
+	t.Run("Makefile", func(t *testing.T) {
+		dir := t.TempDir()
+		m, _ := newMaker()
+		xt.Eq(t, 0, m.make("shims", "-dir", dir))
+
+		data, err := os.ReadFile(filepath.Join(dir, "Makefile"))
+		xt.OK(t, err)
+		exp := shimHeader + `
+# Targets forward to gomake; use ARGS to pass flags, for example:
+#   make build ARGS="-tag 1.0.0"
+
+GOMAKE ?= go run ./cmd/make
+
+.PHONY: help build tools\:lint
+
+# Shows the available targets
+help:
+	@$(GOMAKE) help
+
+# Builds the application
+build:
+	@$(GOMAKE) build $(ARGS)
+
+tools\:lint:
+	@$(GOMAKE) tools:lint $(ARGS)
+`
+		xt.Eq(t, exp, string(data))
+
+		if _, err := exec.LookPath("make"); err == nil {
+			out, err := exec.Command("make", "-s", "-C", dir, "GOMAKE=echo", "ARGS=-fix", "tools:lint").CombinedOutput()
+			xt.OK(t, err, string(out))
+			xt.Eq(t, "tools:lint -fix\n", string(out))
+		}
+	})
+
+	t.Run("justfile", func(t *testing.T) {
+		dir := t.TempDir()
+		m, _ := newMaker()
+		xt.Eq(t, 0, m.make("shims", "-dir", dir, "-just"))
+
+		data, err := os.ReadFile(filepath.Join(dir, "justfile"))
+		xt.OK(t, err)
+		just := string(data)
+		xt.Assert(t, strings.Contains(just, `gomake := env_var_or_default("GOMAKE", "go run ./cmd/make")`), just)
+		xt.Assert(t, strings.Contains(just, "# Builds the application\nbuild *args:\n    @{{gomake}} build {{args}}\n"), just)
+		xt.Assert(t, strings.Contains(just, "tools-lint *args:\n    @{{gomake}} tools:lint {{args}}\n"), just)
+	})
+
+	t.Run("check", func(t *testing.T) {
+		dir := t.TempDir()
+		m, stdErr := newMaker()
+		xt.Eq(t, 1, m.make("shims", "-dir", dir, "-check"))
+		xt.Assert(t, strings.Contains(stdErr.String(), "Makefile is out of date"), stdErr.String())
+
+		xt.Eq(t, 0, m.make("shims", "-dir", dir))
+		xt.Eq(t, 0, m.make("shims", "-dir", dir, "-check"))
+
+		m.registerTargets(NewTarget("test", nil))
+		xt.Eq(t, 1, m.make("shims", "-dir", dir, "-check"))
+	})
+
+	t.Run("registered targets take precedence over built-ins", func(t *testing.T) {
+		dir := t.TempDir()
+		var ran []string
+		record := func(target *Target) error {
+			ran = append(ran, target.Name+" "+strings.Join(target.FlagArgs, " "))
+			return nil
+		}
+
+		m, _ := newMaker()
+		m.registerTargets(NewTarget("shims", record), NewTarget("logs", record), NewTarget("graph", record))
+		xt.Eq(t, 0, m.make("shims", "-dir", dir))
+		xt.Eq(t, 0, m.make("logs"))
+		xt.Eq(t, 0, m.make("graph"))
+		xt.Eq(t, []string{"shims -dir " + dir, "logs ", "graph "}, ran)
+
+		_, err := os.Stat(filepath.Join(dir, "Makefile"))
+		xt.Assert(t, os.IsNotExist(err), "built-in shims must not have run")
+	})
+
+	t.Run("files not generated are kept", func(t *testing.T) {
+		dir := t.TempDir()
+		p := filepath.Join(dir, "Makefile")
+		xt.OK(t, os.WriteFile(p, []byte("all:\n"), 0o600))
+
+		m, stdErr := newMaker()
+		xt.Eq(t, 1, m.make("shims", "-dir", dir))
+		xt.Assert(t, strings.Contains(stdErr.String(), "was not generated by gomake; use -force to overwrite"),
+			stdErr.String())
+
+		xt.Eq(t, 0, m.make("shims", "-dir", dir, "-force"))
+		data, err := os.ReadFile(p)
+		xt.OK(t, err)
+		xt.Assert(t, strings.HasPrefix(string(data), shimHeader))
+	})
+}
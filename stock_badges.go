@@ -18,35 +18,25 @@ var TargetBadges = Target{
 	Name:         "badges",
 	PreMessages:  []string{"generating badges"},
 	PostMessages: []string{"done generating badges"},
-	HandleFlags: func(target *Target) (*flag.FlagSet, error) {
-		flagSet := flag.NewFlagSet(target.Name, flag.ExitOnError)
-		if target.Flags == nil {
-			target.Flags = map[string]any{}
-		}
-
-		var (
-			configFile string
-			destFolder string
-		)
-
-		flagSet.StringVar(&configFile, "config", filepath.Join("_badges", "badges.json"),
+	DefineFlags: func(flagSet *flag.FlagSet) {
+		flagSet.String("config", filepath.Join("_badges", "badges.json"),
 			"Configuration file containing which badges to generate")
-		flagSet.StringVar(&destFolder, "dest", "_badges/",
+		flagSet.String("dest", "_badges/",
 			"Folder in which fetched badges will be stored")
-
-		if err := flagSet.Parse(target.FlagArgs); err != nil {
+	},
+	HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+		flagSet, err := target.ParseFlags()
+		if err != nil {
 			return nil, err
 		}
 
-		if configFile != "" {
-			target.Flags["config"] = configFile
-		} else {
+		target.StoreFlag(flagSet, "config", "config")
+		if target.Flags["config"] == "" {
 			return nil, fmt.Errorf("config file is required")
 		}
 
-		if configFile != "" {
-			target.Flags["destFolder"] = destFolder
-		} else {
+		target.StoreFlag(flagSet, "dest", "destFolder")
+		if target.Flags["destFolder"] == "" {
 			return nil, fmt.Errorf("destination folder is required")
 		}
 
@@ -57,15 +47,20 @@ var TargetBadges = Target{
 			return err
 		}
 
-		sb, err := shieldbadger.NewShieldBadger(target.Flags["config"].(string))
+		sb, err := shieldbadger.NewShieldBadger(target.Path(target.Flags["config"].(string)))
 		if err != nil {
 			return err
 		}
 
 		sb.FeedbackCallback = func(format string, a ...any) {
-			target.Maker.Printf(format, a...)
+			target.Maker.Log.Infof(format, a...)
 		}
 
 		return sb.Fetch()
 	},
 }
+
+// NewBadgesTarget returns a copy of TargetBadges customized using opts.
+func NewBadgesTarget(opts ...TargetOption) *Target {
+	return newTarget(&TargetBadges, opts...)
+}
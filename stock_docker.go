@@ -5,7 +5,6 @@ package gomake
 import (
 	"flag"
 	"fmt"
-	"io"
 	"math/rand"
 	"net/url"
 	"os/exec"
@@ -20,78 +19,43 @@ var TargetDockerBuild = Target{
 	PostMessages:    []string{"done building Docker image"},
 	DeferredTargets: nil,
 	PreTargets:      nil,
+	DefineFlags: func(flagSet *flag.FlagSet) {
+		flagSet.String("registry", "", "Docker registry to be used when naming the image")
+		flagSet.String("image", "", "Docker image name")
+		flagSet.String("tag", "", "Docker Image tag (usually version)")
+		flagSet.String("f", "", `Name of the Dockerfile (default: "PATH/Dockerfile")`)
+		flagSet.Bool("no-cache", false, "Do not use cache when building the Docker image")
+	},
 	HandleFlags: func(target *Target) (*flag.FlagSet, error) {
-		flagSet := flag.NewFlagSet(target.Name, flag.ExitOnError)
-		if target.Flags == nil {
-			target.Flags = map[string]any{}
-		}
-
-		var (
-			registry   string
-			image      string
-			tag        string
-			dockerFile string
-			noCache    bool
-		)
-
-		flagSet.StringVar(&registry, "registry", "",
-			"Docker registry to be used when naming the image")
-		flagSet.StringVar(&image, "image", "", "Docker image name")
-		flagSet.StringVar(&tag, "tag", "", "Docker Image tag (usually version)")
-		flagSet.StringVar(&dockerFile, "f", "", `Name of the Dockerfile (default: "PATH/Dockerfile")`)
-		flagSet.BoolVar(&noCache, "no-cache", false, "Do not use cache when building the Docker image")
-
-		if err := flagSet.Parse(target.FlagArgs); err != nil {
+		flagSet, err := target.ParseFlags()
+		if err != nil {
 			return nil, err
 		}
 
-		if registry != "" {
-			// command line flag override default
-			if registry == "docker.io" || registry == "local" {
-				registry = "docker.io"
-			}
-
-			target.Flags["registry"] = registry
+		target.StoreFlag(flagSet, "registry", "registry")
+		switch target.Flags["registry"] {
+		case "":
+			target.Maker.Log.Info("registry not set, default docker.io/library will be used")
+		case "docker.io", "local":
+			target.Flags["registry"] = "docker.io"
 		}
 
-		if v, ok := target.Flags["registry"]; !ok || v == "" {
-			fmt.Println("Note: registry not set, default docker.io/library will be used.")
+		target.StoreFlag(flagSet, "image", "image")
+		image, _ := target.Flags["image"].(string)
+		if image == "" {
+			return nil, fmt.Errorf("%s: flag -image is required", target.Name)
 		}
-
 		if target.Flags["registry"] == "docker.io" {
-			// correct image name (checked later when empty)
-			if image, ok := target.Flags["image"].(string); ok && image != "" {
-				target.Flags["image"] = "library/" + image[strings.LastIndex(image, "/")+1:]
-			}
-		} else if image != "" {
-			// command line flag override default
-			target.Flags["image"] = image
+			target.Flags["image"] = "library/" + image[strings.LastIndex(image, "/")+1:]
 		}
 
-		if v, ok := target.Flags["image"]; !ok || v == "" {
-			if image == "" {
-				return nil, fmt.Errorf("%s: flag -image is required", target.Name)
-			}
+		target.StoreFlag(flagSet, "tag", "tag")
+		if target.Flags["tag"] == "" {
+			return nil, fmt.Errorf("%s: flag -tag is required", target.Name)
 		}
 
-		if tag != "" {
-			// command line flag override default
-			target.Flags["tag"] = tag
-		}
-
-		if v, ok := target.Flags["tag"]; !ok || v == "" {
-			if tag == "" {
-				return nil, fmt.Errorf("%s: flag -tag is required", target.Name)
-			}
-		}
-
-		if v, ok := target.Flags["dockerFile"]; !ok || v == "" {
-			target.Flags["dockerFile"] = dockerFile
-		}
-
-		if _, ok := target.Flags["noCache"]; !ok {
-			target.Flags["noCache"] = noCache
-		}
+		target.StoreFlag(flagSet, "f", "dockerFile")
+		target.StoreFlag(flagSet, "no-cache", "noCache")
 
 		return flagSet, nil
 	},
@@ -102,9 +66,9 @@ var TargetDockerBuild = Target{
 
 		tag := fmt.Sprintf("%s:%s", target.Flags["image"].(string), target.Flags["tag"].(string))
 
-		if r, ok := target.Flags["registry"]; ok {
+		if r, ok := target.Flags["registry"].(string); ok && r != "" {
 			var err error
-			tag, err = url.JoinPath(r.(string), tag)
+			tag, err = url.JoinPath(r, tag)
 			if err != nil {
 				return fmt.Errorf("failed creating tag using registry (%w)", err)
 			}
@@ -120,10 +84,11 @@ var TargetDockerBuild = Target{
 			execArgs = append(execArgs, "--no-cache")
 		}
 
-		if err := execDocker(target.Maker.StdOut, target.Maker.StdErr, execArgs, target.WorkDir); err != nil {
+		if err := execDocker(target, execArgs); err != nil {
 			return err
 		}
 
+		target.SetOutput("image", tag)
 		return nil
 	},
 }
@@ -136,66 +101,30 @@ var TargetDockerBuildXPush = Target{
 	PostMessages:    []string{"done building Docker image"},
 	DeferredTargets: nil,
 	PreTargets:      nil,
+	DefineFlags: func(flagSet *flag.FlagSet) {
+		flagSet.String("registry", "", "Docker registry to push too (authentication must be done before)")
+		flagSet.String("image", "", "Docker image name")
+		flagSet.String("tag", "", "Docker image tag (usually version)")
+		flagSet.String("platform", "linux/arm64,linux/amd64", "Platforms to build for (comma separated)")
+		flagSet.String("f", "", `Name of the Dockerfile (default: "PATH/Dockerfile")`)
+		flagSet.Bool("no-cache", false, "Do not use cache when building the Docker image")
+	},
 	HandleFlags: func(target *Target) (*flag.FlagSet, error) {
-		flagSet := flag.NewFlagSet(target.Name, flag.ExitOnError)
-		if target.Flags == nil {
-			target.Flags = map[string]any{}
-		}
-
-		var (
-			registry   string
-			image      string
-			tag        string
-			platform   string
-			dockerFile string
-			noCache    bool
-		)
-
-		flagSet.StringVar(&registry, "registry", "",
-			"Docker registry to push too (authentication must be done before)")
-		flagSet.StringVar(&image, "image", "", "Docker image name")
-		flagSet.StringVar(&tag, "tag", "", "Docker image tag (usually version)")
-		flagSet.StringVar(&platform, "platform", "linux/arm64,linux/amd64",
-			"Platforms to build for (comma separated)")
-		flagSet.StringVar(&dockerFile, "f", "", `Name of the Dockerfile (default: "PATH/Dockerfile")`)
-		flagSet.BoolVar(&noCache, "no-cache", false, "Do not use cache when building the Docker image")
-
-		if err := flagSet.Parse(target.FlagArgs); err != nil {
+		flagSet, err := target.ParseFlags()
+		if err != nil {
 			return nil, err
 		}
 
-		if v, ok := target.Flags["registry"]; !ok || v == "" {
-			if registry == "" {
-				return nil, fmt.Errorf("%s: flag -registry is required", target.Name)
-			}
-			target.Flags["registry"] = registry
-		}
-
-		if v, ok := target.Flags["image"]; !ok || v == "" {
-			if image == "" {
-				return nil, fmt.Errorf("%s: flag -image is required", target.Name)
+		for _, name := range []string{"registry", "image", "tag"} {
+			target.StoreFlag(flagSet, name, name)
+			if target.Flags[name] == "" {
+				return nil, fmt.Errorf("%s: flag -%s is required", target.Name, name)
 			}
-			target.Flags["image"] = image
-		}
-
-		if v, ok := target.Flags["tag"]; !ok || v == "" {
-			if tag == "" {
-				return nil, fmt.Errorf("%s: flag -tag is required", target.Name)
-			}
-			target.Flags["tag"] = tag
-		}
-
-		if v, ok := target.Flags["platform"]; !ok || v == "" {
-			target.Flags["platform"] = platform
 		}
 
-		if v, ok := target.Flags["dockerFile"]; !ok || v == "" {
-			target.Flags["dockerFile"] = dockerFile
-		}
-
-		if _, ok := target.Flags["noCache"]; !ok {
-			target.Flags["noCache"] = noCache
-		}
+		target.StoreFlag(flagSet, "platform", "platform")
+		target.StoreFlag(flagSet, "f", "dockerFile")
+		target.StoreFlag(flagSet, "no-cache", "noCache")
 
 		return flagSet, nil
 	},
@@ -217,7 +146,7 @@ var TargetDockerBuildXPush = Target{
 			"--use",
 		}
 
-		if err := execDocker(target.Maker.StdOut, target.Maker.StdErr, execArgs, target.WorkDir); err != nil {
+		if err := execDocker(target, execArgs); err != nil {
 			return err
 		}
 
@@ -225,7 +154,7 @@ var TargetDockerBuildXPush = Target{
 			execArgs := []string{
 				"buildx", "rm", "-f", builderName,
 			}
-			_ = execDocker(target.Maker.StdOut, target.Maker.StdErr, execArgs, target.WorkDir)
+			_ = execDocker(target, execArgs)
 		}()
 
 		execArgs = []string{
@@ -245,25 +174,33 @@ var TargetDockerBuildXPush = Target{
 
 		execArgs = append(execArgs, "--push", ".")
 
-		if err := execDocker(target.Maker.StdOut, target.Maker.StdErr, execArgs, target.WorkDir); err != nil {
+		if err := execDocker(target, execArgs); err != nil {
 			return err
 		}
 
+		target.SetOutput("image", fullTag)
 		return nil
 	},
 }
 
-func execDocker(stdOut, stdErr io.Writer, args []string, workDir string) error {
+func execDocker(target *Target, args []string) error {
 	cmd := exec.Command("docker", args...)
-	cmd.Stdout = stdOut
-	cmd.Stderr = stdErr
-	if workDir != "" {
-		fmt.Println("executing in directory:", workDir)
-		cmd.Dir = workDir
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
+	cmd.Stdout = target.Maker.StdOut
+	cmd.Stderr = target.Maker.StdErr
+
+	return target.RunCmd(cmd)
+}
+
+// NewDockerBuildTarget returns a copy of TargetDockerBuild customized using
+// opts. For example:
+//
+//	NewDockerBuildTarget(WithFlags(map[string]any{"image": "myapp", "tag": "1.0.0"}))
+func NewDockerBuildTarget(opts ...TargetOption) *Target {
+	return newTarget(&TargetDockerBuild, opts...)
+}
+
+// NewDockerBuildXPushTarget returns a copy of TargetDockerBuildXPush
+// customized using opts.
+func NewDockerBuildXPushTarget(opts ...TargetOption) *Target {
+	return newTarget(&TargetDockerBuildXPush, opts...)
 }
@@ -8,26 +8,22 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 )
 
-func targetVendorHandleFlags(target *Target) (*flag.FlagSet, error) {
-	flagSet := flag.NewFlagSet(target.Name, flag.ExitOnError)
-	if target.Flags == nil {
-		target.Flags = map[string]any{}
-	}
-
-	var out string
-	flagSet.StringVar(&out, "out", "vendor", "create vendor directory at given path")
+func targetVendorDefineFlags(flagSet *flag.FlagSet) {
+	flagSet.String("out", "vendor", "create vendor directory at given path")
+}
 
-	if err := flagSet.Parse(target.FlagArgs); err != nil {
+func targetVendorHandleFlags(target *Target) (*flag.FlagSet, error) {
+	flagSet, err := target.ParseFlags()
+	if err != nil {
 		return nil, err
 	}
 
-	if v, ok := target.Flags["out"]; !ok || v == "" {
-		target.Flags["out"] = out
-	}
+	target.StoreFlag(flagSet, "out", "out")
 
 	return flagSet, nil
 }
@@ -36,6 +32,7 @@ var TargetVendor = Target{
 	Name:         "vendor",
 	PreMessages:  []string{"running go mod vendor command"},
 	PostMessages: []string{"done running go mod vendor command"},
+	DefineFlags:  targetVendorDefineFlags,
 	HandleFlags:  targetVendorHandleFlags,
 	Do: func(target *Target) error {
 		if _, err := target.HandleFlags(target); err != nil {
@@ -52,7 +49,7 @@ var TargetVendor = Target{
 			}
 		}
 		cmd := exec.Command("go", execArgs...)
-		if err := cmd.Run(); err != nil {
+		if err := target.RunCmd(cmd); err != nil {
 			return err
 		}
 		return nil
@@ -63,6 +60,7 @@ var TargetCleanupVendor = Target{
 	Name:         "clean-vendor",
 	PreMessages:  []string{"removing vendor folder"},
 	PostMessages: []string{"done removing vendor folder"},
+	DefineFlags:  targetVendorDefineFlags,
 	HandleFlags:  targetVendorHandleFlags,
 	Do: func(target *Target) error {
 		if _, err := target.HandleFlags(target); err != nil {
@@ -79,7 +77,7 @@ var TargetCleanupVendor = Target{
 			}
 		}
 
-		return os.RemoveAll(vendorPath)
+		return os.RemoveAll(target.Path(vendorPath))
 	},
 }
 
@@ -94,11 +92,14 @@ var TargetGoVersion = Target{
 		cmd := exec.Command("go", "version")
 		cmd.Stdout = &buf
 		cmd.Stderr = &buf
-		if err := cmd.Run(); err != nil {
+		if err := target.RunCmd(cmd); err != nil {
 			return err
 		}
 
-		target.Maker.Println(strings.TrimSpace(buf.String()))
+		version := strings.TrimSpace(buf.String())
+		target.SetOutput("version", version)
+
+		_, _ = fmt.Fprintln(target.Maker.StdOut, version)
 		return nil
 	},
 }
@@ -117,23 +118,19 @@ var TargetGoLint = Target{
 		cmd.Stdout = &bufOut
 		cmd.Stderr = &bufErr
 
-		if err := cmd.Start(); err != nil {
-			return err
-		}
-
-		err := cmd.Wait()
+		err := target.RunCmd(cmd)
 		if err != nil {
 			switch err.(type) {
 			case *exec.ExitError:
-				fmt.Println(bufOut.String())
-				fmt.Println(bufErr.String())
+				_, _ = fmt.Fprint(target.Maker.StdOut, bufOut.String())
+				_, _ = fmt.Fprint(target.Maker.StdErr, bufErr.String())
 				return nil
 			default:
 				return err
 			}
 		}
 
-		target.Maker.Println("Congrats! Looking good!")
+		target.Maker.Log.Info("Congrats! Looking good!")
 		return nil
 	},
 }
@@ -152,24 +149,17 @@ var TargetGoCoverage = Target{
 			{"go", "run", "-cover", "./cmd/make", "go-lint"},
 		},
 	},
-	HandleFlags: func(target *Target) (*flag.FlagSet, error) {
-		flagSet := flag.NewFlagSet(target.Name, flag.ExitOnError)
-		if target.Flags == nil {
-			target.Flags = map[string]any{}
-		}
-
-		var coverdir string
-
-		flagSet.StringVar(&coverdir, "coverdir", "",
+	DefineFlags: func(flagSet *flag.FlagSet) {
+		flagSet.String("coverdir", "",
 			"Where to store coverage profiles (default: create a system temporary directory)")
-
-		if err := flagSet.Parse(target.FlagArgs); err != nil {
+	},
+	HandleFlags: func(target *Target) (*flag.FlagSet, error) {
+		flagSet, err := target.ParseFlags()
+		if err != nil {
 			return nil, err
 		}
 
-		if v, ok := target.Flags["coverdir"]; !ok || v == "" {
-			target.Flags["coverdir"] = coverdir
-		}
+		target.StoreFlag(flagSet, "coverdir", "coverdir")
 
 		return flagSet, nil
 	},
@@ -180,17 +170,20 @@ var TargetGoCoverage = Target{
 			return fmt.Errorf("integration setting not slice of string slices")
 		}
 
-		result, err := combinedCoverage(target.Maker, coverDir, integration)
+		result, err := combinedCoverage(target, coverDir, integration)
 		if err != nil {
 			return err
 		}
 
-		target.Maker.Println("Total Coverage:", result)
+		target.SetOutput("coverage", result)
+
+		_, _ = fmt.Fprintln(target.Maker.StdOut, "Total Coverage:", result)
 		return nil
 	},
 }
 
-func combinedCoverage(maker *Maker, coverDir string, integration [][]string) (string, error) {
+func combinedCoverage(target *Target, coverDir string, integration [][]string) (string, error) {
+	maker := target.Maker
 	var bufErr strings.Builder
 
 	if strings.TrimSpace(coverDir) == "" {
@@ -201,17 +194,22 @@ func combinedCoverage(maker *Maker, coverDir string, integration [][]string) (st
 		}
 		defer func() { _ = os.RemoveAll(coverDir) }()
 	} else {
+		var err error
+		// commands run in the work directory of the target
+		if coverDir, err = filepath.Abs(target.Path(coverDir)); err != nil {
+			return "", err
+		}
 		if err := os.Mkdir(coverDir, 0770); err != nil {
 			switch {
 			case os.IsExist(err):
-				fmt.Println("Coverage output directory exists; you are responsible to clean it up before and after")
+				maker.Log.Warn("coverage output directory exists; you are responsible to clean it up before and after")
 			case err != nil:
 				return "", err
 			}
 		}
 	}
 
-	fmt.Println("Coverage profiles stored in", coverDir)
+	maker.Log.Info("coverage profiles stored in", coverDir)
 
 	dirUnit := path.Join(coverDir, "unittests")
 	if err := os.Mkdir(dirUnit, 0700); err != nil {
@@ -222,16 +220,15 @@ func combinedCoverage(maker *Maker, coverDir string, integration [][]string) (st
 		return "", err
 	}
 
-	env := os.Environ()
-	env = append(env, "GOCOVERDIR="+dirIntegration)
+	env := []string{"GOCOVERDIR=" + dirIntegration}
 
-	maker.Println("Coverage using unittests")
+	maker.Log.Info("coverage using unittests")
 	cmd := []string{"go", "test", "-cover", "./...",
 		"-args", fmt.Sprintf("-test.gocoverdir=%s", dirUnit)}
-	if err := execCmd(nil, &bufErr, nil, cmd...); err != nil {
+	if err := execCmd(target, nil, &bufErr, nil, cmd...); err != nil {
 		switch err.(type) {
 		case *exec.ExitError:
-			fmt.Println(bufErr.String())
+			_, _ = fmt.Fprint(maker.StdErr, bufErr.String())
 			return "", nil
 		default:
 			return "", err
@@ -239,16 +236,16 @@ func combinedCoverage(maker *Maker, coverDir string, integration [][]string) (st
 	}
 
 	if len(integration) > 0 {
-		maker.Println("Coverage using integration")
+		maker.Log.Info("coverage using integration")
 	}
 
 	for _, cmdAndArgs := range integration {
-		fmt.Println("  Running:", strings.Join(cmdAndArgs, " "))
+		maker.Log.Info("  running:", strings.Join(cmdAndArgs, " "))
 		var bufErr strings.Builder
-		if err := execCmd(nil, &bufErr, env, cmdAndArgs...); err != nil {
+		if err := execCmd(target, nil, &bufErr, env, cmdAndArgs...); err != nil {
 			switch err.(type) {
 			case *exec.ExitError:
-				fmt.Println(bufErr.String())
+				_, _ = fmt.Fprint(maker.StdErr, bufErr.String())
 				return "", nil
 			default:
 				return "", err
@@ -262,10 +259,10 @@ func combinedCoverage(maker *Maker, coverDir string, integration [][]string) (st
 		"-i", dirIntegration + "," + dirUnit,
 		"-o", path.Join(coverDir, "profile"),
 	}
-	if err := execCmd(&bufOut, &bufErr, nil, cmdAndArgs...); err != nil {
+	if err := execCmd(target, &bufOut, &bufErr, nil, cmdAndArgs...); err != nil {
 		switch err.(type) {
 		case *exec.ExitError:
-			fmt.Println(bufErr.String())
+			_, _ = fmt.Fprint(maker.StdErr, bufErr.String())
 			return "", nil
 		default:
 			return "", err
@@ -276,10 +273,10 @@ func combinedCoverage(maker *Maker, coverDir string, integration [][]string) (st
 	cmdAndArgs = []string{
 		"go", "tool", "cover", "-func", path.Join(coverDir, "profile"),
 	}
-	if err := execCmd(&bufOut, &bufErr, nil, cmdAndArgs...); err != nil {
+	if err := execCmd(target, &bufOut, &bufErr, nil, cmdAndArgs...); err != nil {
 		switch err.(type) {
 		case *exec.ExitError:
-			fmt.Println(bufErr.String())
+			_, _ = fmt.Fprint(maker.StdErr, bufErr.String())
 			return "", nil
 		default:
 			return "", err
@@ -294,3 +291,30 @@ func combinedCoverage(maker *Maker, coverDir string, integration [][]string) (st
 
 	return m[1], nil
 }
+
+// NewVendorTarget returns a copy of TargetVendor customized using opts.
+func NewVendorTarget(opts ...TargetOption) *Target {
+	return newTarget(&TargetVendor, opts...)
+}
+
+// NewCleanupVendorTarget returns a copy of TargetCleanupVendor customized using
+// opts.
+func NewCleanupVendorTarget(opts ...TargetOption) *Target {
+	return newTarget(&TargetCleanupVendor, opts...)
+}
+
+// NewGoVersionTarget returns a copy of TargetGoVersion customized using opts.
+func NewGoVersionTarget(opts ...TargetOption) *Target {
+	return newTarget(&TargetGoVersion, opts...)
+}
+
+// NewGoLintTarget returns a copy of TargetGoLint customized using opts.
+func NewGoLintTarget(opts ...TargetOption) *Target {
+	return newTarget(&TargetGoLint, opts...)
+}
+
+// NewGoCoverageTarget returns a copy of TargetGoCoverage customized using opts.
+// Use WithSetting to provide the integration commands.
+func NewGoCoverageTarget(opts ...TargetOption) *Target {
+	return newTarget(&TargetGoCoverage, opts...)
+}
@@ -3,7 +3,14 @@
 package gomake
 
 import (
+	"errors"
 	"flag"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
 )
 
 type Target struct {
@@ -13,12 +20,169 @@ type Target struct {
 	Description     string
 	FlagArgs        []string
 	Flags           map[string]any
+	DefineFlags     func(flagSet *flag.FlagSet)
 	HandleFlags     func(target *Target) (*flag.FlagSet, error)
 	PreMessages     []string
 	PostMessages    []string
 	DeferredTargets []*Target
 	PreTargets      []*Target
 	Do              func(*Target) error
-	WorkDir         string
 	Settings        map[string]any
+
+	// FlagEnvVars maps names of flags to environment variables setting them,
+	// in addition to the variable named by FlagEnv, which takes precedence.
+	FlagEnvVars map[string]string
+
+	// WorkDir is the directory in which commands of the target are run, and
+	// against which relative paths given to the target are resolved.
+	WorkDir string
+	// Env defines the environment of the commands run by the target.
+	Env Env
+	// Stdin, when not nil, is the standard input of the commands run by the
+	// target; for example os.Stdin for interactive commands.
+	Stdin io.Reader
+
+	// Stem is the part of the name matched by `%` when the target is an
+	// instance of a pattern target; see Instance.
+	Stem string
+	// Params holds the parameters of sub-targets of matrix targets; see
+	// NewMatrixTarget.
+	Params map[string]string
+
+	// children are the targets combined by Seq, Par, OnlyIf and Finally.
+	children []*Target
+	// combinator is the kind of combinator combining children, such as seq.
+	combinator string
+	// registerChildren, when true, registers children together with the
+	// target.
+	registerChildren bool
+	// pattern is the name of the pattern target of which the target is an
+	// instance.
+	pattern string
+	// instances holds the instances of a pattern target by stem.
+	instances map[string]*Target
+	// plugin is the path of the executable of plugin targets.
+	plugin string
+	// outputs holds the values published using SetOutput outside a run.
+	outputs map[string]any
+
+	report *TargetReport
+}
+
+// RunCmd starts cmd and waits for it to finish. The command, its exit code,
+// duration and resource usage are recorded in the run report of the Maker, and
+// observers of the Maker are notified of the command and its output.
+//
+// Unless set, the directory of cmd is the work directory of the target, and
+// its standard input that of the target. Variables in cmd.Env are added to the
+// environment of the target.
+func (t *Target) RunCmd(cmd *exec.Cmd) error {
+	if cmd.Dir == "" {
+		cmd.Dir = t.WorkDir
+	}
+	if cmd.Stdin == nil && t.Stdin != nil {
+		cmd.Stdin = t.Stdin
+	}
+	cmd.Env = t.environ(cmd.Env)
+
+	cr := &CommandReport{
+		Args: cmd.Args,
+		Dir:  cmd.Dir,
+	}
+
+	if t.Maker != nil {
+		var lineWriters []*lineWriter
+		capture := func(w io.Writer, stream string) io.Writer {
+			// files, such as a terminal, are passed on as they are unless
+			// observers need the lines of output
+			if _, ok := w.(*os.File); ok && !t.Maker.observesOutput() {
+				return w
+			}
+			lw := newLineWriter(w, t.outputEmitter(cr, stream))
+			lineWriters = append(lineWriters, lw)
+			return lw
+		}
+
+		stdOut, stdErr := cmd.Stdout, cmd.Stderr
+		_, isFile := stdOut.(*os.File)
+		if stdOut != nil && stdOut == stdErr && (!isFile || t.Maker.observesOutput()) {
+			// exec.Cmd serializes writes when both are the same, and so must we
+			stdOut = &syncWriter{w: stdOut}
+			stdErr = stdOut
+		}
+		cmd.Stdout, cmd.Stderr = capture(stdOut, StreamStdOut), capture(stdErr, StreamStdErr)
+
+		defer func() {
+			for _, lw := range lineWriters {
+				lw.flush()
+			}
+			t.Maker.emit(Event{Kind: EventCommandEnd, Target: t, Command: cr, Err: cmdError(cr)})
+		}()
+	}
+
+	cr.Started = time.Now()
+	if t.Maker != nil {
+		if cmd.Dir != "" {
+			t.Maker.Log.Debug("executing in directory:", cmd.Dir)
+		}
+		t.Maker.emit(Event{Kind: EventCommandStart, Time: cr.Started, Target: t, Command: cr})
+	}
+
+	err := cmd.Run()
+
+	cr.Duration = time.Since(cr.Started)
+	if state := cmd.ProcessState; state != nil {
+		cr.UserCPU = state.UserTime()
+		cr.SystemCPU = state.SystemTime()
+		cr.MaxRSS = maxRSS(state)
+	}
+	if err != nil {
+		cr.Error = err.Error()
+		cr.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			cr.ExitCode = exitErr.ExitCode()
+		}
+	}
+
+	if t.report != nil {
+		t.report.Commands = append(t.report.Commands, cr)
+		t.report.ResourceUsage.add(cr.ResourceUsage)
+	}
+
+	return err
+}
+
+// Path returns path relative to the work directory of the target, unless
+// path is absolute or no work directory is set.
+func (t *Target) Path(path string) string {
+	if t.WorkDir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(t.WorkDir, path)
+}
+
+func (t *Target) outputEmitter(cr *CommandReport, stream string) func(line string) {
+	return func(line string) {
+		t.Maker.emit(Event{Kind: EventOutput, Target: t, Command: cr, Stream: stream, Line: t.Maker.Secrets.Mask(line)})
+	}
+}
+
+func cmdError(cr *CommandReport) error {
+	if cr.Error == "" {
+		return nil
+	}
+	return errors.New(cr.Error)
+}
+
+// syncWriter serializes writes to w.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.w.Write(p)
 }
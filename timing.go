@@ -0,0 +1,140 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// WriteTimings writes a table to w showing the wall time of each target,
+// the longest running first. The time of a target includes its pre-targets.
+func (r *Report) WriteTimings(w io.Writer) error {
+	targets := make([]*TargetReport, len(r.Targets))
+	copy(targets, r.Targets)
+	sort.SliceStable(targets, func(i, j int) bool {
+		return targets[i].Duration > targets[j].Duration
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "Target\tStatus\tDuration\tCommands\tCommand Time")
+	for _, tr := range targets {
+		var cmdTime time.Duration
+		for _, c := range tr.Commands {
+			cmdTime += c.Duration
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n",
+			tr.Name, tr.Status, roundDuration(tr.Duration), len(tr.Commands), roundDuration(cmdTime))
+	}
+	_, _ = fmt.Fprintf(tw, "Total\t\t%s\t\t\n", roundDuration(r.Duration))
+
+	return tw.Flush()
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d > time.Second:
+		return d.Round(time.Millisecond)
+	case d > time.Millisecond:
+		return d.Round(time.Microsecond)
+	default:
+		return d
+	}
+}
+
+// traceEvent is an event in the Chrome Trace Event Format, as understood by
+// chrome://tracing and https://ui.perfetto.dev.
+type traceEvent struct {
+	Name      string         `json:"name"`
+	Category  string         `json:"cat"`
+	Phase     string         `json:"ph"`
+	Timestamp int64          `json:"ts"`
+	Duration  int64          `json:"dur"`
+	PID       int            `json:"pid"`
+	TID       int            `json:"tid"`
+	Args      map[string]any `json:"args,omitempty"`
+}
+
+type traceFile struct {
+	TraceEvents     []traceEvent `json:"traceEvents"`
+	DisplayTimeUnit string       `json:"displayTimeUnit"`
+}
+
+// ChromeTrace returns the report in the Chrome Trace Event Format. Each target
+// and each command is a complete event; events of pre-targets and commands
+// nest within the target which started them. Targets executed in parallel
+// using Par are shown in separate threads.
+func (r *Report) ChromeTrace() ([]byte, error) {
+	micro := func(t time.Time) int64 {
+		return t.Sub(r.Started).Microseconds()
+	}
+
+	trace := traceFile{
+		TraceEvents:     []traceEvent{},
+		DisplayTimeUnit: "ms",
+	}
+
+	for _, tr := range r.Targets {
+		lane := tr.lane
+		if lane == 0 {
+			lane = 1
+		}
+
+		args := map[string]any{
+			"status": tr.Status,
+		}
+		if tr.Error != "" {
+			args["error"] = tr.Error
+		}
+
+		trace.TraceEvents = append(trace.TraceEvents, traceEvent{
+			Name:      tr.Name,
+			Category:  "target",
+			Phase:     "X",
+			Timestamp: micro(tr.Started),
+			Duration:  tr.Duration.Microseconds(),
+			PID:       1,
+			TID:       lane,
+			Args:      args,
+		})
+
+		for _, c := range tr.Commands {
+			trace.TraceEvents = append(trace.TraceEvents, traceEvent{
+				Name:      strings.Join(c.Args, " "),
+				Category:  "command",
+				Phase:     "X",
+				Timestamp: micro(c.Started),
+				Duration:  c.Duration.Microseconds(),
+				PID:       1,
+				TID:       lane,
+				Args: map[string]any{
+					"exitCode": c.ExitCode,
+					"target":   tr.Name,
+				},
+			})
+		}
+	}
+
+	return json.Marshal(trace)
+}
+
+// WriteChromeTrace writes the report in the Chrome Trace Event Format to the
+// file at path.
+func (r *Report) WriteChromeTrace(path string) error {
+	data, err := r.ChromeTrace()
+	if err != nil {
+		return fmt.Errorf("creating trace %s (%w)", path, err)
+	}
+
+	if err := os.WriteFile(path, data, 0o640); err != nil {
+		return fmt.Errorf("writing trace (%w)", err)
+	}
+
+	return nil
+}
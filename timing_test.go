@@ -0,0 +1,74 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golistic/xt"
+)
+
+func TestTimings(t *testing.T) {
+	pre := &Target{
+		Name: "pre",
+		Do: func(target *Target) error {
+			return target.RunCmd(exec.Command("go", "version"))
+		},
+	}
+
+	main := &Target{
+		Name:       "main",
+		PreTargets: []*Target{pre},
+		Do: func(target *Target) error {
+			return nil
+		},
+	}
+
+	t.Run("summary table", func(t *testing.T) {
+		var buf strings.Builder
+		m := NewMaker()
+		m.StdOut = &buf
+		m.Timings = true
+		m.registerTargets(main)
+
+		xt.Eq(t, 0, m.make("main"))
+
+		out := buf.String()
+		xt.Assert(t, strings.Contains(out, "Target  Status"), out)
+		xt.MatchString(t, `(?m)^main\s+succeeded`, out)
+		xt.MatchString(t, `(?m)^pre\s+succeeded\s+\S+\s+1\s`, out)
+		xt.MatchString(t, `(?m)^Total\s+\S+`, out)
+	})
+
+	t.Run("chrome trace", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "trace.json")
+
+		m := NewMaker()
+		m.StdOut = &strings.Builder{}
+		m.TraceFile = path
+		m.registerTargets(main)
+
+		xt.Eq(t, 0, m.make("main"))
+
+		data, err := os.ReadFile(path)
+		xt.OK(t, err)
+
+		var trace traceFile
+		xt.OK(t, json.Unmarshal(data, &trace))
+		xt.Eq(t, 3, len(trace.TraceEvents))
+
+		parent, child, cmd := trace.TraceEvents[0], trace.TraceEvents[1], trace.TraceEvents[2]
+		xt.Eq(t, "main", parent.Name)
+		xt.Eq(t, "pre", child.Name)
+		xt.Eq(t, "go version", cmd.Name)
+		xt.Eq(t, "command", cmd.Category)
+		xt.Assert(t, child.Timestamp >= parent.Timestamp, "pre-target must start within target")
+		xt.Assert(t, child.Timestamp+child.Duration <= parent.Timestamp+parent.Duration,
+			"pre-target must end within target")
+	})
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"github.com/BurntSushi/toml"
+)
+
+// parseTOML parses data as TOML document. Integers are decoded as int64,
+// floats as float64, arrays as []any and tables as map[string]any.
+func parseTOML(data []byte) (map[string]any, error) {
+	raw := map[string]any{}
+	if _, err := toml.Decode(string(data), &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2023, Geert JM Vanderkelen
+
+package gomake
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAML parses data as YAML document, which must be a mapping. Values
+// are normalized to the types decoded from TOML: integers are int64, and
+// mappings are map[string]any.
+func parseYAML(data []byte) (map[string]any, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return map[string]any{}, nil
+	}
+
+	raw, ok := normalizeYAML(doc).(map[string]any)
+	if !ok {
+		return nil, errors.New("document must be a mapping")
+	}
+	return raw, nil
+}
+
+// normalizeYAML returns v, as decoded by yaml.v3, with integers converted to
+// int64 and mappings to map[string]any.
+func normalizeYAML(v any) any {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case []any:
+		for i, e := range v {
+			v[i] = normalizeYAML(e)
+		}
+		return v
+	case map[string]any:
+		for k, e := range v {
+			v[k] = normalizeYAML(e)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalizeYAML(e)
+		}
+		return m
+	default:
+		return v
+	}
+}